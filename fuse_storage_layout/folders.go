@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/storage/control/apiv2/controlpb"
+	"google.golang.org/api/iterator"
+)
+
+// listFolders lists the folders under -prefix in -bucket with ListFolders,
+// -page-size folders per page, and reports the folder count and the latency
+// of each page. Folders only exist in buckets with hierarchical namespace,
+// so other buckets are rejected up front.
+func listFolders(ctx context.Context) error {
+	controlClient, err := newControlClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer controlClient.Close()
+
+	layout, err := controlClient.GetStorageLayout(ctx, &controlpb.GetStorageLayoutRequest{
+		Name: fmt.Sprintf("projects/_/buckets/%s/storageLayout", *bucket),
+	})
+	if err != nil {
+		return fmt.Errorf("get storage layout: %w", err)
+	}
+	if !layout.GetHierarchicalNamespace().GetEnabled() {
+		return fmt.Errorf("bucket %s doesn't have hierarchical namespace enabled, so it has no folders to list", *bucket)
+	}
+
+	it := controlClient.ListFolders(ctx, &controlpb.ListFoldersRequest{
+		Parent:    fmt.Sprintf("projects/_/buckets/%s", *bucket),
+		Prefix:    *prefix,
+		Delimiter: *delimiter,
+	})
+	pager := iterator.NewPager(it, *pageSize, "")
+
+	var (
+		count     int
+		latencies []time.Duration
+	)
+	start := time.Now()
+	for {
+		var page []*controlpb.Folder
+		t := time.Now()
+		token, err := pager.NextPage(&page)
+		if err != nil {
+			return fmt.Errorf("list folders: %w", err)
+		}
+		d := time.Since(t)
+		latencies = append(latencies, d)
+		count += len(page)
+		fmt.Printf("page %d: %d folders in %v\n", len(latencies), len(page), d)
+		if token == "" {
+			break
+		}
+	}
+	total := time.Since(start)
+
+	slices.Sort(latencies)
+	fmt.Printf("%d folders under %q in %d pages in %v; page latency min %v median %v max %v\n",
+		count, *prefix, len(latencies), total, latencies[0], latencies[len(latencies)/2], latencies[len(latencies)-1])
+	return nil
+}
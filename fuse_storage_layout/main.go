@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 
@@ -11,13 +12,43 @@ import (
 	"google.golang.org/api/option"
 )
 
+var (
+	bucket  = flag.String("bucket", "mhall-golang-test", "bucket")
+	project = flag.String("project", "", "project that owns -bucket; required for get-bucket-metrics")
+	op      = flag.String("op", "get-storage-layout", "operation; get-storage-layout, get-bucket-metrics, list-folders")
+
+	prefix    = flag.String("prefix", "", "folder name prefix for list-folders")
+	delimiter = flag.String("delimiter", "", "delimiter for list-folders; \"/\" lists only the folders directly under -prefix")
+	pageSize  = flag.Int("page-size", 1000, "folders per page for list-folders")
+)
+
 func main() {
+	flag.Parse()
 	ctx := context.Background()
 
+	switch *op {
+	case "get-storage-layout":
+		getStorageLayout(ctx)
+	case "get-bucket-metrics":
+		if err := getBucketMetrics(ctx); err != nil {
+			log.Fatalf("get-bucket-metrics: %v", err)
+		}
+	case "list-folders":
+		if err := listFolders(ctx); err != nil {
+			log.Fatalf("list-folders: %v", err)
+		}
+	default:
+		log.Fatalf("invalid -op %q", *op)
+	}
+}
+
+// newControlClient creates a storage control client with the default
+// credentials.
+func newControlClient(ctx context.Context) (*control.StorageControlClient, error) {
 	scope := "https://www.googleapis.com/auth/devstorage.full_control"
 	tokenSrc, err := google.DefaultTokenSource(ctx, scope)
 	if err != nil {
-		log.Fatalf("JWTAccessTokenSourceWithScope: %w", err)
+		return nil, fmt.Errorf("JWTAccessTokenSourceWithScope: %w", err)
 	}
 
 	// Create client options
@@ -25,7 +56,15 @@ func main() {
 
 	controlClient, err := control.NewStorageControlClient(ctx, clientOpts...)
 	if err != nil {
-		log.Fatalf("failed to create control client: %v", err)
+		return nil, fmt.Errorf("failed to create control client: %w", err)
+	}
+	return controlClient, nil
+}
+
+func getStorageLayout(ctx context.Context) {
+	controlClient, err := newControlClient(ctx)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	fmt.Println("Successfully created control client:", controlClient)
@@ -34,7 +73,7 @@ func main() {
 	req := &controlpb.GetStorageLayoutRequest{
 		// Define your request parameters here.  For example:
 		// Name: "projects/storage-sdks-madisonhall/buckets/mhall-golang-test/storageLayout",
-		Name: "projects/_/buckets/mhall-golang-test/storageLayout",
+		Name: fmt.Sprintf("projects/_/buckets/%s/storageLayout", *bucket),
 	}
 
 	layout, err := controlClient.GetStorageLayout(ctx, req)
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	monitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// getBucketMetrics prints the object count and total bytes of -bucket.
+//
+// The Storage Control API has no bucket size or object count, so this reads
+// the storage/object_count and storage/total_bytes metrics from Cloud
+// Monitoring instead. GCS samples them about once a day, so the snapshot can
+// be up to a day old.
+func getBucketMetrics(ctx context.Context) error {
+	if *project == "" {
+		return errors.New("-project is required")
+	}
+	c, err := monitoring.NewMetricClient(ctx)
+	if err != nil {
+		return fmt.Errorf("monitoring.NewMetricClient: %w", err)
+	}
+	defer c.Close()
+
+	count, at, err := latestMetric(ctx, c, "storage.googleapis.com/storage/object_count")
+	if err != nil {
+		return err
+	}
+	bytes, _, err := latestMetric(ctx, c, "storage.googleapis.com/storage/total_bytes")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("gs://%s: %.0f objects, %.0f bytes (sampled %v)\n", *bucket, count, bytes, at.Format(time.RFC3339))
+	return nil
+}
+
+// latestMetric sums the most recent point of every time series of metric for
+// -bucket over the last two days. The metrics have one series per storage
+// class.
+func latestMetric(ctx context.Context, c *monitoring.MetricClient, metric string) (float64, time.Time, error) {
+	now := time.Now()
+	it := c.ListTimeSeries(ctx, &monitoringpb.ListTimeSeriesRequest{
+		Name:   "projects/" + *project,
+		Filter: fmt.Sprintf("metric.type=%q AND resource.labels.bucket_name=%q", metric, *bucket),
+		Interval: &monitoringpb.TimeInterval{
+			StartTime: timestamppb.New(now.Add(-48 * time.Hour)),
+			EndTime:   timestamppb.New(now),
+		},
+		View: monitoringpb.ListTimeSeriesRequest_FULL,
+	})
+
+	var (
+		total  float64
+		at     time.Time
+		series int
+	)
+	for {
+		ts, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("list %s: %w", metric, err)
+		}
+		if len(ts.Points) == 0 {
+			continue
+		}
+		// Points are returned newest first.
+		p := ts.Points[0]
+		total += p.Value.GetDoubleValue() + float64(p.Value.GetInt64Value())
+		if t := p.Interval.EndTime.AsTime(); t.After(at) {
+			at = t
+		}
+		series++
+	}
+	if series == 0 {
+		return 0, time.Time{}, fmt.Errorf("no %s data for gs://%s in the last 48h", metric, *bucket)
+	}
+	return total, at, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+var predefinedACLs = []string{"authenticatedRead", "bucketOwnerFullControl", "bucketOwnerRead", "private", "projectPrivate", "publicRead"}
+
+// validatePredefinedACL checks -predefined-acl names a predefined ACL.
+func validatePredefinedACL() error {
+	if *predefinedACL == "" || slices.Contains(predefinedACLs, *predefinedACL) {
+		return nil
+	}
+	return fmt.Errorf("invalid -predefined-acl %q; want one of %s", *predefinedACL, strings.Join(predefinedACLs, ", "))
+}
+
+// explainACLError makes the error GCS returns for an ACL on a bucket with
+// uniform bucket-level access say so.
+func explainACLError(err error) error {
+	if *predefinedACL != "" && strings.Contains(strings.ToLower(err.Error()), "uniform bucket-level access") {
+		return fmt.Errorf("-predefined-acl %s is not allowed: bucket %s has uniform bucket-level access enabled: %w", *predefinedACL, *bucketFlag, err)
+	}
+	return err
+}
+
+// reportACL prints the ACL applied to the uploaded object o.
+func reportACL(ctx context.Context, o *storage.ObjectHandle) {
+	rules, err := client.Bucket(o.BucketName()).Object(o.ObjectName()).ACL().List(ctx)
+	if err != nil {
+		fmt.Printf("acl of %s: can't read back: %v\n", o.ObjectName(), err)
+		return
+	}
+	entries := make([]string, len(rules))
+	for i, r := range rules {
+		entries[i] = fmt.Sprintf("%s:%s", r.Entity, r.Role)
+	}
+	fmt.Printf("acl of %s (%s): %s\n", o.ObjectName(), *predefinedACL, strings.Join(entries, ", "))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+var errBudgetExceeded = errors.New("max-bytes exceeded")
+
+// transferBudget counts the bytes uploaded and downloaded across the run and
+// cancels the run's context once the -max-bytes limit is passed, aborting
+// any in-flight reads or writes.
+type transferBudget struct {
+	limit  int64
+	n      atomic.Int64
+	up     atomic.Int64
+	cancel context.CancelCauseFunc
+}
+
+func newTransferBudget(limit int64, cancel context.CancelCauseFunc) *transferBudget {
+	return &transferBudget{limit: limit, cancel: cancel}
+}
+
+func (b *transferBudget) add(n int) {
+	if b.n.Add(int64(n)) > b.limit && b.limit > 0 {
+		b.cancel(errBudgetExceeded)
+	}
+}
+
+// transferred returns the bytes moved so far.
+func (b *transferBudget) transferred() int64 {
+	return b.n.Load()
+}
+
+// uploaded returns the bytes moved so far by uploadReader.
+func (b *transferBudget) uploaded() int64 {
+	return b.up.Load()
+}
+
+// uploadReader is reader for upload payload, which is also counted apart
+// from downloads.
+func (b *transferBudget) uploadReader(r io.Reader) io.Reader {
+	return &countingReader{r: r, b: b, up: true}
+}
+
+// reader counts bytes read through r against the budget.
+func (b *transferBudget) reader(r io.Reader) io.Reader {
+	return &countingReader{r: r, b: b}
+}
+
+type countingReader struct {
+	r  io.Reader
+	b  *transferBudget
+	up bool
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.b.add(n)
+	if c.up {
+		c.b.up.Add(int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// readCache is an in-process LRU of object ranges, prototyping a client-side
+// read cache. It's keyed by object and range only, so it never sees
+// overwrites; that's fine for measuring the benefit, not for production.
+type readCache struct {
+	mu       sync.Mutex
+	capacity int64
+	used     int64
+	lru      *list.List
+	items    map[string]*list.Element
+
+	hits, misses    int
+	hitLat, missLat []time.Duration
+}
+
+type cacheEntry struct {
+	key  string
+	data []byte
+}
+
+// reads is the read cache, or nil without -cache-reads.
+var reads *readCache
+
+func newReadCache(capacity int64) *readCache {
+	return &readCache{capacity: capacity, lru: list.New(), items: map[string]*list.Element{}}
+}
+
+func (c *readCache) get(key string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return 0, false
+	}
+	c.lru.MoveToFront(e)
+	return int64(len(e.Value.(*cacheEntry).data)), true
+}
+
+func (c *readCache) put(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if int64(len(data)) > c.capacity {
+		return
+	}
+	if _, ok := c.items[key]; ok {
+		return
+	}
+	c.items[key] = c.lru.PushFront(&cacheEntry{key: key, data: data})
+	c.used += int64(len(data))
+	for c.used > c.capacity {
+		e := c.lru.Back()
+		ent := c.lru.Remove(e).(*cacheEntry)
+		delete(c.items, ent.key)
+		c.used -= int64(len(ent.data))
+	}
+}
+
+func (c *readCache) observe(hit bool, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hit {
+		c.hits++
+		c.hitLat = append(c.hitLat, d)
+	} else {
+		c.misses++
+		c.missLat = append(c.missLat, d)
+	}
+}
+
+func (c *readCache) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ratio := 0.0
+	if total := c.hits + c.misses; total > 0 {
+		ratio = 100 * float64(c.hits) / float64(total)
+	}
+	return fmt.Sprintf("%d hits, %d misses (hit ratio %.1f%%)\n  cached:   %s\n  uncached: %s",
+		c.hits, c.misses, ratio, latencySummary(c.hitLat), latencySummary(c.missLat))
+}
+
+// readRange reads length bytes of o from offset, or to the end of the object
+// if length is negative, and returns the number of bytes read. With
+// -cache-reads, repeated reads of the same range are served from memory.
+func readRange(ctx context.Context, o *storage.ObjectHandle, offset, length int64) (n int64, err error) {
+	key := fmt.Sprintf("%s/%s:%d:%d", o.BucketName(), o.ObjectName(), offset, length)
+	start := time.Now()
+	if reads != nil {
+		if n, ok := reads.get(key); ok {
+			reads.observe(true, time.Since(start))
+			return n, nil
+		}
+	}
+
+	r, err := newRangeReader(ctx, o, offset, length)
+	if err != nil {
+		return 0, fmt.Errorf("new reader: %w", err)
+	}
+	defer r.Close()
+
+	var (
+		buf bytes.Buffer
+		dst = io.Discard
+		src = budget.reader(r)
+		pf  *persistFile
+	)
+	if reads != nil {
+		dst = &buf
+	}
+	if *downloadTo != "" {
+		if pf, err = createDownloadFile(o.ObjectName(), offset, offset == 0 && length < 0); err != nil {
+			return 0, fmt.Errorf("-download-to: %w", err)
+		}
+		if reads != nil {
+			dst = io.MultiWriter(&buf, pf)
+		} else {
+			dst = pf
+		}
+		src = pf.from(src)
+	}
+	n, err = io.Copy(dst, src)
+	if pf != nil {
+		if cErr := pf.close(); err == nil && cErr != nil {
+			err = fmt.Errorf("-download-to: %w", cErr)
+		}
+	}
+	if err != nil {
+		return n, fmt.Errorf("io.Copy: %w", err)
+	}
+	if length >= 0 && n != length {
+		return n, fmt.Errorf("read %d bytes, want %d", n, length)
+	}
+	if reads != nil {
+		reads.put(key, buf.Bytes())
+		reads.observe(false, time.Since(start))
+	}
+	return n, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	mrand "math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// payload returns the bytes to upload: random, or with -seed the same
+// deterministic stream on every run so its checksum is known in advance.
+func payload() io.Reader {
+	if *seed == 0 {
+		return rand.Reader
+	}
+	var s [32]byte
+	binary.LittleEndian.PutUint64(s[:], *seed)
+	return mrand.NewChaCha8(s)
+}
+
+// payloadCRC32C returns the CRC32C of the first size bytes of the seeded
+// payload.
+func payloadCRC32C(size int64) uint32 {
+	h := crc32.New(castagnoli)
+	io.CopyN(h, payload(), size)
+	return h.Sum32()
+}
+
+// sendCRC32C resolves -send-crc32c for an upload of size bytes. It accepts
+// a hex ("0x...") or decimal checksum, or "auto" to compute it from the
+// seeded payload.
+func sendCRC32C(size int64) (uint32, error) {
+	s := strings.TrimSpace(*sendCRC32CFlag)
+	if s == "auto" {
+		if *seed == 0 {
+			return 0, fmt.Errorf("-send-crc32c=auto requires -seed")
+		}
+		return payloadCRC32C(size), nil
+	}
+	base := 10
+	if h, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		s, base = h, 16
+	}
+	n, err := strconv.ParseUint(s, base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid -send-crc32c %q: want hex, decimal or auto", *sendCRC32CFlag)
+	}
+	return uint32(n), nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// chunkTimer records the time between successive chunk flushes of a
+// storage.Writer, as reported through its ProgressFunc. Single-request
+// uploads never call ProgressFunc and so record nothing.
+type chunkTimer struct {
+	last      time.Time
+	lastBytes int64
+	latencies []time.Duration
+	stalls    int
+}
+
+func newChunkTimer() *chunkTimer {
+	return &chunkTimer{last: time.Now()}
+}
+
+// progress is a storage.Writer ProgressFunc.
+func (c *chunkTimer) progress(n int64) {
+	now := time.Now()
+	d := now.Sub(c.last)
+	c.latencies = append(c.latencies, d)
+	if *chunkStall > 0 && d > *chunkStall {
+		c.stalls++
+		log.Printf("chunk %d (bytes %d-%d) took %v, over -chunk-stall %v", len(c.latencies), c.lastBytes, n, d, *chunkStall)
+	}
+	c.last = now
+	c.lastBytes = n
+}
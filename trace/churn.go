@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"google.golang.org/api/iterator"
+)
+
+// churn uploads an object and then overwrites it -churn-count times in
+// sequence, each write producing a new generation, and reports the latency
+// of each overwrite. With -churn-precondition every overwrite is conditional
+// on the generation the previous write produced; since nothing else writes
+// the object, any precondition failure is unexpected and is reported.
+// Afterwards the object's generations are listed and checked against the
+// ones written.
+func churn(ctx context.Context) error {
+	name := fmt.Sprintf("%s%s_%s", *downscopePrefix, "churn", uuid.New().String())
+	o := client.Bucket(*bucketFlag).Object(name)
+	size := int64(*objectSize)
+
+	attrs, err := writeObject(ctx, o.If(storage.Conditions{DoesNotExist: true}), size)
+	if err != nil {
+		return fmt.Errorf("initial upload: %w", err)
+	}
+	fmt.Printf("churn %s: generation %d\n", name, attrs.Generation)
+
+	var (
+		latencies []time.Duration
+		failed    int
+		gen       = attrs.Generation
+		written   = []int64{attrs.Generation}
+	)
+	for i := range *churnCount {
+		results.setIteration(i)
+		h := o
+		if *churnPrecondition {
+			h = o.If(storage.Conditions{GenerationMatch: gen})
+		}
+		start := time.Now()
+		attrs, err := writeObject(ctx, h, size)
+		d := time.Since(start)
+		if err != nil {
+			results.fail("churn", d, err)
+			if *churnPrecondition && isPreconditionFailed(err) {
+				// Keep going from the live generation so one failure
+				// doesn't fail every later overwrite too.
+				failed++
+				fmt.Printf("overwrite %d: unexpected precondition failure on generation %d\n", i, gen)
+				if cur, aErr := metadataClient().Bucket(*bucketFlag).Object(name).Attrs(ctx); aErr == nil {
+					gen = cur.Generation
+				}
+				continue
+			}
+			return fmt.Errorf("overwrite %d: %w", i, err)
+		}
+		latencies = append(latencies, d)
+		results.record("churn", d, size)
+		fmt.Printf("overwrite %d: %v, generation %d -> %d\n", i, d, gen, attrs.Generation)
+		gen = attrs.Generation
+		written = append(written, gen)
+	}
+
+	fmt.Printf("churn overwrite latency: %s\n", latencySummary(latencies))
+	if *churnPrecondition {
+		fmt.Printf("unexpected precondition failures: %d of %d\n", failed, *churnCount)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d overwrites failed their generation precondition", failed)
+	}
+	return checkGenerations(ctx, name, written)
+}
+
+// checkGenerations lists the generations of name and checks they are the
+// history churn wrote. With versioning every written generation is kept;
+// without it only the last is live, and the ones it replaced are
+// soft-deleted if the bucket has a soft delete policy, or gone otherwise.
+func checkGenerations(ctx context.Context, name string, written []int64) error {
+	attrs, err := metadataClient().Bucket(*bucketFlag).Attrs(ctx)
+	if err != nil {
+		return fmt.Errorf("Bucket(%q).Attrs: %w", *bucketFlag, err)
+	}
+	last := written[len(written)-1]
+	listed, err := listGenerations(ctx, &storage.Query{Prefix: name, Versions: true}, name)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	check := func(what string, got, want []int64) {
+		gotSet := map[int64]bool{}
+		for _, g := range got {
+			gotSet[g] = true
+		}
+		wantSet := map[int64]bool{}
+		for _, g := range want {
+			wantSet[g] = true
+			if !gotSet[g] {
+				problems = append(problems, fmt.Sprintf("%s generation %d is missing", what, g))
+			}
+		}
+		for _, g := range got {
+			if !wantSet[g] {
+				problems = append(problems, fmt.Sprintf("unexpected %s generation %d", what, g))
+			}
+		}
+	}
+	switch {
+	case attrs.VersioningEnabled:
+		check("versioned", listed, written)
+	default:
+		check("live", listed, []int64{last})
+		if p := attrs.SoftDeletePolicy; p != nil && p.RetentionDuration > 0 {
+			deleted, err := listGenerations(ctx, &storage.Query{Prefix: name, SoftDeleted: true}, name)
+			if err != nil {
+				return err
+			}
+			check("soft-deleted", deleted, written[:len(written)-1])
+		}
+	}
+
+	fmt.Printf("churn %s: %d generations written, versioning %t, %d listed with Versions\n",
+		name, len(written), attrs.VersioningEnabled, len(listed))
+	for _, p := range problems {
+		fmt.Printf("generation history: %s\n", p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("generation history of %s has %d discrepancies", name, len(problems))
+	}
+	return nil
+}
+
+// listGenerations returns the generations q lists for exactly name.
+func listGenerations(ctx context.Context, q *storage.Query, name string) ([]int64, error) {
+	var gens []int64
+	it := metadataClient().Bucket(*bucketFlag).Objects(ctx, q)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return gens, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Bucket(%q).Objects: %w", *bucketFlag, err)
+		}
+		if attrs.Name == name {
+			gens = append(gens, attrs.Generation)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"cloud.google.com/go/storage"
+)
+
+// createdObject is an object generation written by this run.
+type createdObject struct {
+	name string
+	gen  int64
+}
+
+// created records every object generation the run uploads, so -cleanup
+// deletes exactly those and nothing written by anyone else since.
+var created struct {
+	mu   sync.Mutex
+	objs []createdObject
+}
+
+func recordCreated(name string, gen int64) {
+	created.mu.Lock()
+	defer created.mu.Unlock()
+	created.objs = append(created.objs, createdObject{name: name, gen: gen})
+}
+
+// cleanup deletes the objects the run created, each conditional on its
+// generation still being live.
+func cleanup(ctx context.Context) {
+	created.mu.Lock()
+	objs := created.objs
+	created.objs = nil
+	created.mu.Unlock()
+
+	var deleted, skipped int
+	for _, c := range objs {
+		o := client.Bucket(*bucketFlag).Object(c.name).If(storage.Conditions{GenerationMatch: c.gen})
+		err := o.Delete(ctx)
+		switch {
+		case err == nil:
+			deleted++
+		case isPreconditionFailed(err):
+			skipped++
+			fmt.Printf("cleanup: not deleting %s: generation %d is no longer live\n", c.name, c.gen)
+		default:
+			log.Printf("cleanup: delete %s#%d: %v", c.name, c.gen, err)
+		}
+	}
+	fmt.Printf("cleanup: deleted %d of %d objects, %d skipped by precondition\n", deleted, len(objs), skipped)
+}
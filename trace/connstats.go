@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+
+	"google.golang.org/grpc/stats"
+)
+
+// connStats counts requests that went out on a reused connection versus a
+// freshly dialed one.
+type connStats struct {
+	requests atomic.Int64
+	fresh    atomic.Int64
+}
+
+var conns connStats
+
+// reused returns the number of requests that didn't need a new connection.
+func (c *connStats) reused() int64 {
+	return max(c.requests.Load()-c.fresh.Load(), 0)
+}
+
+func (c *connStats) String() string {
+	req, fresh := c.requests.Load(), c.fresh.Load()
+	ratio := 0.0
+	if req > 0 {
+		ratio = 100 * float64(c.reused()) / float64(req)
+	}
+	return fmt.Sprintf("%d requests, %d reused a connection, %d opened a new one (reuse ratio %.1f%%)", req, c.reused(), fresh, ratio)
+}
+
+// connTrackingTransport records, through httptrace, whether each HTTP request
+// got a reused connection.
+type connTrackingTransport struct {
+	next  http.RoundTripper
+	stats *connStats
+}
+
+func (t *connTrackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.stats.requests.Add(1)
+			if !info.Reused {
+				t.stats.fresh.Add(1)
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	return t.next.RoundTrip(req)
+}
+
+// connStatsHandler is a gRPC stats.Handler counting RPCs and the connections
+// opened to carry them. Every RPC beyond the first on a connection counts as
+// reuse.
+type connStatsHandler struct {
+	stats *connStats
+}
+
+func (h *connStatsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *connStatsHandler) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if _, ok := s.(*stats.Begin); ok {
+		h.stats.requests.Add(1)
+	}
+}
+
+func (h *connStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *connStatsHandler) HandleConn(_ context.Context, s stats.ConnStats) {
+	if _, ok := s.(*stats.ConnBegin); ok {
+		h.stats.fresh.Add(1)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// A read that finds no object is retried after visibilityBackoff, doubling
+// up to maxVisibilityBackoff, until the object has been missing for
+// visibilityTimeout.
+const (
+	visibilityBackoff    = 5 * time.Millisecond
+	maxVisibilityBackoff = 200 * time.Millisecond
+	visibilityTimeout    = 30 * time.Second
+)
+
+// rawConsistency measures read-after-write latency over -iterations rounds:
+// each uploads a new object and, as soon as the write returns, reads its
+// first byte back, retrying on not found with a short backoff, and records
+// the time from the write returning to the first successful read and how
+// many reads that took. Reading one byte keeps the object's size out of the
+// time to visibility. GCS is strongly consistent, so any retry at all is
+// worth reporting.
+func rawConsistency(ctx context.Context) error {
+	var (
+		latencies []time.Duration
+		retried   int
+		size      = int64(*objectSize)
+	)
+	for i := range max(*iterations, 1) {
+		results.setIteration(i)
+		o := client.Bucket(*bucketFlag).Object(placedName("raw"))
+		if _, err := writeObject(ctx, o.If(storage.Conditions{DoesNotExist: true}), size); err != nil {
+			return fmt.Errorf("upload: %w", err)
+		}
+
+		written := time.Now()
+		var reads int
+		for wait := visibilityBackoff; ; wait = min(2*wait, maxVisibilityBackoff) {
+			reads++
+			err := readFirstByte(ctx, o)
+			if err == nil {
+				break
+			}
+			if !errors.Is(err, storage.ErrObjectNotExist) {
+				results.fail("read-after-write", time.Since(written), err)
+				return fmt.Errorf("read %s: %w", o.ObjectName(), err)
+			}
+			if time.Since(written) > visibilityTimeout {
+				err = fmt.Errorf("%s still not found after %v and %d reads", o.ObjectName(), visibilityTimeout, reads)
+				results.fail("read-after-write", time.Since(written), err)
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		d := time.Since(written)
+		latencies = append(latencies, d)
+		results.record("read-after-write", d, 1)
+		if reads > 1 {
+			retried++
+			fmt.Printf("iteration %d: %s not found %d times before it could be read after %v\n", i, o.ObjectName(), reads-1, d)
+		}
+	}
+
+	fmt.Printf("read-after-write latency: %s\n", latencySummary(latencies))
+	fmt.Printf("read-after-write: %d of %d objects needed a retry\n", retried, len(latencies))
+	return nil
+}
+
+// readFirstByte reads the first byte of o.
+func readFirstByte(ctx context.Context, o *storage.ObjectHandle) error {
+	r, err := newRangeReader(ctx, o, 0, 1)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(io.Discard, budget.reader(r))
+	return err
+}
+
+// readAll reads o to the end.
+func readAll(ctx context.Context, o *storage.ObjectHandle) error {
+	r, err := newRangeReader(ctx, o, 0, -1)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(io.Discard, budget.reader(r))
+	return err
+}
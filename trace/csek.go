@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// encryptionKey and decryptionKey are the parsed -encryption-key and
+// -decryption-key customer-supplied encryption keys.
+var encryptionKey, decryptionKey []byte
+
+// parseKey decodes a base64 AES-256 key.
+func parseKey(s string) ([]byte, error) {
+	k, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("not base64: %w", err)
+	}
+	if len(k) != 32 {
+		return nil, fmt.Errorf("key is %d bytes, want 32 for AES-256", len(k))
+	}
+	return k, nil
+}
+
+// parseKeys sets encryptionKey and decryptionKey from the flags.
+func parseKeys() error {
+	var err error
+	if *encryptionKeyFlag != "" {
+		if encryptionKey, err = parseKey(*encryptionKeyFlag); err != nil {
+			return fmt.Errorf("-encryption-key: %w", err)
+		}
+	}
+	if *decryptionKeyFlag != "" {
+		if encryptionKey == nil {
+			return fmt.Errorf("-decryption-key needs -encryption-key to rotate to")
+		}
+		if decryptionKey, err = parseKey(*decryptionKeyFlag); err != nil {
+			return fmt.Errorf("-decryption-key: %w", err)
+		}
+	}
+	return nil
+}
+
+// withWriteKey returns o encrypted with the key uploads write under: the old
+// -decryption-key when rotating, else -encryption-key.
+func withWriteKey(o *storage.ObjectHandle) *storage.ObjectHandle {
+	switch {
+	case decryptionKey != nil:
+		return o.Key(decryptionKey)
+	case encryptionKey != nil:
+		return o.Key(encryptionKey)
+	}
+	return o
+}
+
+// rotateKey rewrites o, encrypted with -decryption-key, to -encryption-key
+// and returns the handle to read it back with. Without -decryption-key
+// there's nothing to rotate and o is returned as is.
+func rotateKey(ctx context.Context, o *storage.ObjectHandle) (*storage.ObjectHandle, error) {
+	if decryptionKey == nil {
+		return o, nil
+	}
+	dst := o.Key(encryptionKey)
+	start := time.Now()
+	attrs, err := dst.CopierFrom(o).Run(ctx)
+	if err != nil {
+		results.fail("rotate", time.Since(start), err)
+		return nil, fmt.Errorf("rotate %s: %w", o.ObjectName(), err)
+	}
+	recordCreated(attrs.Name, attrs.Generation)
+	results.record("rotate", time.Since(start), 0)
+	return dst, nil
+}
+
+// reportRotatedRead prints whether the read after a key rotation worked.
+func reportRotatedRead(err error) {
+	if decryptionKey == nil {
+		return
+	}
+	if err != nil {
+		fmt.Printf("key rotation: read with the rotated key failed: %v\n", err)
+		return
+	}
+	fmt.Println("key rotation: read with the rotated key succeeded")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+)
+
+// deadlineCheck reads a large object through an http1, an http2 and a
+// grpc-dp client under a -deadline context, -iterations times each, and
+// checks every read is cancelled within -deadline-tolerance of the deadline
+// passing. It prints the lag from deadline to the read returning per
+// transport. Without -object a fresh -object-size object is uploaded first;
+// it must be large enough not to finish downloading before the deadline.
+func deadlineCheck(ctx context.Context) error {
+	name := *objectFlag
+	if name == "" {
+		name = fmt.Sprintf("%s%s_%s", *downscopePrefix, "deadline", uuid.New().String())
+		o := client.Bucket(*bucketFlag).Object(name).If(storage.Conditions{DoesNotExist: true})
+		if _, err := writeObject(ctx, o, int64(*objectSize)); err != nil {
+			return fmt.Errorf("upload: %w", err)
+		}
+	}
+
+	var (
+		apis  = []string{http1, http2, dp}
+		lags  = make([][]time.Duration, len(apis))
+		late  = make([]int, len(apis))
+		fails int
+	)
+	for j, a := range apis {
+		err := func() error {
+			c := newClient(ctx, a)
+			defer c.Close()
+			o := c.Bucket(*bucketFlag).Object(name)
+			for i := range max(*iterations, 1) {
+				results.setIteration(i)
+				lag, err := readPastDeadline(ctx, o)
+				if err != nil {
+					results.fail(a+" deadline lag", lag, err)
+					return fmt.Errorf("%s: %w", a, err)
+				}
+				lags[j] = append(lags[j], lag)
+				results.record(a+" deadline lag", lag, 0)
+				if lag > *deadlineTolerance {
+					late[j]++
+					fails++
+				}
+			}
+			return nil
+		}()
+		if err != nil {
+			return err
+		}
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "client\tn\tp50\tp90\tp99\tmax\tlate")
+	for j, a := range apis {
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", a, latencyColumns(lags[j]), late[j])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if fails > 0 {
+		return fmt.Errorf("%d reads returned more than -deadline-tolerance %v after the deadline", fails, *deadlineTolerance)
+	}
+	fmt.Printf("deadline-check: every read was cancelled within %v of its %v deadline\n", *deadlineTolerance, *deadline)
+	return nil
+}
+
+// readPastDeadline reads o whole under a -deadline context and returns how
+// long after the deadline the read gave up. It's an error for the read to
+// finish first or to fail for any other reason.
+func readPastDeadline(ctx context.Context, o *storage.ObjectHandle) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, *deadline)
+	defer cancel()
+	due, _ := ctx.Deadline()
+
+	r, err := o.NewRangeReader(ctx, 0, -1)
+	if err == nil {
+		_, err = io.Copy(io.Discard, budget.reader(r))
+		r.Close()
+	}
+	lag := time.Since(due)
+	switch {
+	case err == nil:
+		return 0, fmt.Errorf("read of %s finished before the %v deadline; use a larger object or a shorter -deadline", o.ObjectName(), *deadline)
+	case !isDeadline(err):
+		return 0, fmt.Errorf("read of %s: %w", o.ObjectName(), err)
+	}
+	return lag, nil
+}
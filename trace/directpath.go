@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+)
+
+const directPathEnv = "GOOGLE_CLOUD_ENABLE_DIRECT_PATH_XDS"
+
+// directPathPrefixes are the address ranges of DirectPath backends;
+// CloudPath traffic goes to Google front ends outside them.
+var directPathPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("2001:4860:8040::/42"),
+	netip.MustParsePrefix("34.126.0.0/18"),
+}
+
+// directPathProblems collects why the DirectPath env var may not have been
+// honoured by the grpc-dp client.
+var (
+	directPathMu       sync.Mutex
+	directPathProblems []string
+)
+
+func directPathProblem(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.Printf("warning: %s", msg)
+	directPathMu.Lock()
+	directPathProblems = append(directPathProblems, msg)
+	directPathMu.Unlock()
+}
+
+// enableDirectPath sets the DirectPath env var for grpc-dp before any client
+// or gRPC state is created, warning if the environment already set it to
+// something else.
+func enableDirectPath() {
+	if v, ok := os.LookupEnv(directPathEnv); ok && v != "true" {
+		directPathProblem("%s=%q in the environment is overridden to \"true\"", directPathEnv, v)
+	}
+	if err := os.Setenv(directPathEnv, "true"); err != nil {
+		log.Fatalf("set DP env var: %v", err)
+	}
+}
+
+// checkDirectPathEnv asserts, just before a grpc-dp client is built, that
+// the env var is still set; if it isn't, DirectPath was set too late or
+// unset since, and the client will use CloudPath.
+func checkDirectPathEnv() {
+	if v := os.Getenv(directPathEnv); v != "true" {
+		directPathProblem("%s is %q when building the grpc-dp client; it was unset after startup", directPathEnv, v)
+	}
+}
+
+// directPathVerdict says whether the run really used DirectPath: the env var
+// was honoured, the client targets DirectPath, and every gRPC peer is a
+// DirectPath backend.
+func directPathVerdict() string {
+	directPathMu.Lock()
+	problems := directPathProblems
+	directPathMu.Unlock()
+	if len(problems) > 0 {
+		return "no (" + strings.Join(problems, "; ") + ")"
+	}
+	if _, directPath := clientEndpoint(); !directPath {
+		return "no (not on GCE or the emulator is set, so gRPC uses CloudPath)"
+	}
+
+	peers.mu.Lock()
+	defer peers.mu.Unlock()
+	if len(peers.conns) == 0 {
+		return "unknown (no gRPC connections were made)"
+	}
+	var other []string
+	for ip := range peers.conns {
+		if !isDirectPathPeer(ip) {
+			other = append(other, ip)
+		}
+	}
+	if len(other) > 0 {
+		return fmt.Sprintf("no (%d of %d peers are not DirectPath backends: %s)", len(other), len(peers.conns), strings.Join(other, ", "))
+	}
+	return fmt.Sprintf("yes (%d DirectPath peers)", len(peers.conns))
+}
+
+func isDirectPathPeer(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range directPathPrefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
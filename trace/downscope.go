@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/google/downscope"
+	raw "google.golang.org/api/storage/v1"
+)
+
+// downscopedTokenSource returns a token source whose credentials are limited
+// by a credential access boundary to objects in -bucket whose names start
+// with -downscope-prefix.
+func downscopedTokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	root, err := google.DefaultTokenSource(ctx, raw.CloudPlatformScope)
+	if err != nil {
+		return nil, fmt.Errorf("DefaultTokenSource: %w", err)
+	}
+	cond := fmt.Sprintf("resource.name.startsWith('projects/_/buckets/%s/objects/%s')", *bucketFlag, *downscopePrefix)
+	return downscope.NewTokenSource(ctx, downscope.DownscopingConfig{
+		RootSource: root,
+		Rules: []downscope.AccessBoundaryRule{{
+			AvailableResource:    "//storage.googleapis.com/projects/_/buckets/" + *bucketFlag,
+			AvailablePermissions: []string{"inRole:roles/storage.objectAdmin"},
+			Condition:            &downscope.AvailabilityCondition{Expression: cond},
+		}},
+	})
+}
+
+// checkDownscope verifies that the downscoped client can't see an object
+// outside of -downscope-prefix: the server must answer 403, not 404, for a
+// name the token has no access to.
+func checkDownscope(ctx context.Context) error {
+	name := "outside-downscope-probe"
+	_, err := metadataClient().Bucket(*bucketFlag).Object(name).Attrs(ctx)
+	switch {
+	case isPermissionDenied(err):
+		return nil
+	case err == nil || errors.Is(err, storage.ErrObjectNotExist):
+		return fmt.Errorf("object %q outside prefix %q was accessible with the downscoped token", name, *downscopePrefix)
+	default:
+		return fmt.Errorf("Attrs(%q) outside prefix: %w", name, err)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+
+	"cloud.google.com/go/compute/metadata"
+)
+
+// clientEndpoint returns where the client sends requests: the JSON API base
+// URL for http1/http2, or the gRPC target for grpc-dp, and whether that
+// target is DirectPath. The storage client doesn't expose its endpoint, so
+// this follows the same rules it uses: STORAGE_EMULATOR_HOST overrides
+// everything, and DirectPath is used when it's enabled and the run is on GCE,
+// otherwise gRPC falls back to DNS.
+func clientEndpoint() (endpoint string, directPath bool) {
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if *api == dp {
+			return host, false
+		}
+		return "http://" + host + "/storage/v1/", false
+	}
+	if *api != dp {
+		return "https://storage.googleapis.com/storage/v1/", false
+	}
+	if os.Getenv("GOOGLE_CLOUD_ENABLE_DIRECT_PATH_XDS") == "true" && metadata.OnGCE() {
+		return "google-c2p:///storage.googleapis.com", true
+	}
+	return "dns:///storage.googleapis.com:443", false
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+// endpoints routes batch operations to per-endpoint clients when
+// -endpoint-manifest is set.
+var endpoints *endpointRouter
+
+type endpointRoute struct {
+	prefix   string
+	endpoint string
+}
+
+// endpointRouter maps object name prefixes to endpoints, creating one client
+// per endpoint on first use, and collects read latency per endpoint.
+type endpointRouter struct {
+	routes []endpointRoute // longest prefix first
+
+	mu        sync.Mutex
+	clients   map[string]*storage.Client
+	latencies map[string][]time.Duration
+}
+
+// loadEndpointManifest reads a manifest of "PREFIX ENDPOINT" lines. Blank
+// lines and lines starting with # are ignored. Objects matching no prefix
+// use the default client.
+func loadEndpointManifest(path string) (*endpointRouter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &endpointRouter{clients: map[string]*storage.Client{}, latencies: map[string][]time.Duration{}}
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want PREFIX ENDPOINT, got %q", path, line, text)
+		}
+		r.routes = append(r.routes, endpointRoute{prefix: fields[0], endpoint: fields[1]})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return len(r.routes[i].prefix) > len(r.routes[j].prefix)
+	})
+	return r, nil
+}
+
+// clientFor returns the client for the object name and the endpoint it
+// talks to, "default" when no prefix matches.
+func (r *endpointRouter) clientFor(ctx context.Context, name string) (*storage.Client, string) {
+	if r == nil {
+		return client, "default"
+	}
+	for _, route := range r.routes {
+		if !strings.HasPrefix(name, route.prefix) {
+			continue
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		c, ok := r.clients[route.endpoint]
+		if !ok {
+			c = getClient(ctx, option.WithEndpoint(route.endpoint))
+			r.clients[route.endpoint] = c
+		}
+		return c, route.endpoint
+	}
+	return client, "default"
+}
+
+func (r *endpointRouter) observe(endpoint string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies[endpoint] = append(r.latencies[endpoint], d)
+}
+
+// report prints the read latency seen through each endpoint.
+func (r *endpointRouter) report() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var eps []string
+	for ep := range r.latencies {
+		eps = append(eps, ep)
+	}
+	sort.Strings(eps)
+	for _, ep := range eps {
+		fmt.Printf("endpoint %s: %s\n", ep, latencySummary(r.latencies[ep]))
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// isPermissionDenied reports whether err is a 403 from the JSON API or a
+// PermissionDenied status from gRPC.
+func isPermissionDenied(err error) bool {
+	return isHTTPStatus(err, http.StatusForbidden) || status.Code(err) == codes.PermissionDenied
+}
+
+// isPreconditionFailed reports whether err is a 412 from the JSON API or a
+// FailedPrecondition status from gRPC.
+func isPreconditionFailed(err error) bool {
+	return isHTTPStatus(err, http.StatusPreconditionFailed) || status.Code(err) == codes.FailedPrecondition
+}
+
+func isHTTPStatus(err error, code int) bool {
+	var gErr *googleapi.Error
+	return errors.As(err, &gErr) && gErr.Code == code
+}
+
+// isConflict reports whether err is how GCS rejects a write that lost a race
+// with another writer: a precondition failure, a 409, or an Aborted status.
+func isConflict(err error) bool {
+	return isPreconditionFailed(err) || isHTTPStatus(err, http.StatusConflict) || status.Code(err) == codes.Aborted
+}
+
+// isAlreadyExists reports whether err is how GCS rejects a DoesNotExist
+// write to a name that's taken: a 412 from the JSON API, or FailedPrecondition
+// or AlreadyExists from gRPC.
+func isAlreadyExists(err error) bool {
+	return isPreconditionFailed(err) || status.Code(err) == codes.AlreadyExists
+}
+
+// isDeadline reports whether err is how a call ends when its context's
+// deadline passes: context.DeadlineExceeded, or a DeadlineExceeded or
+// Canceled status from gRPC.
+func isDeadline(err error) bool {
+	c := status.Code(err)
+	return errors.Is(err, context.DeadlineExceeded) || c == codes.DeadlineExceeded || c == codes.Canceled
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// opEvent is the line -stream-events writes for each completed or failed op.
+type opEvent struct {
+	TS         time.Time `json:"ts"`
+	Iteration  int       `json:"iteration"`
+	Op         string    `json:"op"`
+	Bytes      int64     `json:"bytes"`
+	DurationMS float64   `json:"duration_ms"`
+	Err        string    `json:"err,omitempty"`
+}
+
+// eventStream writes ops to their own file, one JSON line each, so a
+// consumer can follow a run without waiting for the summary or picking the
+// lines out of everything else printed to stdout. Each line is written
+// unbuffered, so events recorded before a fatal error are kept.
+type eventStream struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+func newEventStream(path string) (*eventStream, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &eventStream{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// add is a summary subscriber.
+func (s *eventStream) add(r opResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enc.Encode(opEvent{
+		TS:         r.End,
+		Iteration:  r.Iteration,
+		Op:         r.Name,
+		Bytes:      r.Bytes,
+		DurationMS: r.DurationMS,
+		Err:        r.Err,
+	})
+}
+
+func (s *eventStream) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// timedExporter records how long each export batch takes and how many spans
+// made it out.
+type timedExporter struct {
+	sdktrace.SpanExporter
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	exported  atomic.Int64
+}
+
+func (e *timedExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	start := time.Now()
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	d := time.Since(start)
+
+	e.mu.Lock()
+	e.latencies = append(e.latencies, d)
+	e.mu.Unlock()
+	if err == nil {
+		e.exported.Add(int64(len(spans)))
+	}
+	return err
+}
+
+// roundRobinProcessor spreads ended spans across several batch processors,
+// each with its own exporter, so a single exporter doesn't become the
+// bottleneck.
+type roundRobinProcessor struct {
+	procs     []sdktrace.SpanProcessor
+	exporters []*timedExporter
+	next      atomic.Uint64
+	ended     atomic.Int64
+}
+
+// newExportPipeline builds n batch processors over exporters made by
+// newExporter.
+func newExportPipeline(n int, newExporter func() (sdktrace.SpanExporter, error)) (*roundRobinProcessor, error) {
+	rr := &roundRobinProcessor{}
+	for range max(n, 1) {
+		exp, err := newExporter()
+		if err != nil {
+			return nil, err
+		}
+		te := &timedExporter{SpanExporter: exp}
+		rr.exporters = append(rr.exporters, te)
+		rr.procs = append(rr.procs, sdktrace.NewBatchSpanProcessor(te))
+	}
+	return rr, nil
+}
+
+func (p *roundRobinProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	for _, proc := range p.procs {
+		proc.OnStart(parent, s)
+	}
+}
+
+func (p *roundRobinProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.ended.Add(1)
+	i := p.next.Add(1) % uint64(len(p.procs))
+	p.procs[i].OnEnd(s)
+}
+
+func (p *roundRobinProcessor) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, proc := range p.procs {
+		errs = append(errs, proc.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *roundRobinProcessor) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, proc := range p.procs {
+		errs = append(errs, proc.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// dropped returns how many ended spans haven't been exported.
+func (p *roundRobinProcessor) dropped() int64 {
+	var exported int64
+	for _, e := range p.exporters {
+		exported += e.exported.Load()
+	}
+	return p.ended.Load() - exported
+}
+
+// String reports export latency and how many spans never got exported,
+// which after shutdown means they were dropped by a full queue or a failed
+// export.
+func (p *roundRobinProcessor) String() string {
+	var (
+		latencies []time.Duration
+		exported  int64
+	)
+	for _, e := range p.exporters {
+		e.mu.Lock()
+		latencies = append(latencies, e.latencies...)
+		e.mu.Unlock()
+		exported += e.exported.Load()
+	}
+	return fmt.Sprintf("%d exporters, %d of %d spans exported, %d dropped; export latency %s",
+		len(p.exporters), exported, p.ended.Load(), p.ended.Load()-exported, latencySummary(latencies))
+}
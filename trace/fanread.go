@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/api/iterator"
+)
+
+// fanRead lists up to -fanout objects under -prefix and reads each of them in
+// full using -concurrency workers, so that many connections from the pool are
+// in use at once. With -endpoint-manifest each object is read through the
+// client for its prefix's endpoint.
+func fanRead(ctx context.Context, withSpan bool) error {
+	names, err := listNames(ctx, *prefix, *fanout)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no objects found under prefix %q", *prefix)
+	}
+
+	var total atomic.Int64
+	start := time.Now()
+	err = forEach(names, *concurrency, func(worker int, name string) error {
+		c, endpoint := endpoints.clientFor(ctx, name)
+		n, d, err := readObject(ctx, c.Bucket(*bucketFlag).Object(name), withSpan)
+		total.Add(n)
+		if err != nil {
+			results.failOn(worker, "fan-read", d, err)
+			return fmt.Errorf("read %q via %s: %w", name, endpoint, err)
+		}
+		if endpoints != nil {
+			endpoints.observe(endpoint, d)
+		}
+		results.recordOn(worker, "fan-read", d, n)
+		fmt.Printf("read %s: %d bytes in %v (completed at +%v)\n", name, n, d, time.Since(start).Round(time.Millisecond))
+		return nil
+	})
+
+	wall := time.Since(start)
+	fmt.Printf("fan-read %d objects with %d workers: %d bytes in %v (%.2f MiB/s aggregate)\n",
+		len(names), *concurrency, total.Load(), wall, mibps(total.Load(), wall))
+	if endpoints != nil {
+		endpoints.report()
+	}
+	return err
+}
+
+// listNames returns up to limit object names under prefix, or all of them
+// if limit is 0.
+func listNames(ctx context.Context, prefix string, limit int) ([]string, error) {
+	var names []string
+	it := metadataClient().Bucket(*bucketFlag).Objects(ctx, &storage.Query{Prefix: prefix})
+	for limit <= 0 || len(names) < limit {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Bucket(%q).Objects: %w", *bucketFlag, err)
+		}
+		names = append(names, attrs.Name)
+	}
+	return names, nil
+}
+
+// readObject reads o in full and returns the number of bytes read and the
+// time taken.
+func readObject(ctx context.Context, o *storage.ObjectHandle, withSpan bool) (n int64, runTime time.Duration, err error) {
+	if withSpan {
+		ctxs, span := tracer().Start(ctx, "readobject", opKind("download"))
+		ctx = ctxs
+		span.SetAttributes(
+			attribute.KeyValue{Key: "object", Value: attribute.StringValue(o.ObjectName())},
+			attribute.KeyValue{Key: "mykey", Value: attribute.StringValue(*api)},
+		)
+		defer span.End()
+	}
+
+	ctx, endNested := nestSpans(ctx, *spanDepth)
+	defer endNested()
+
+	defer logOp(ctx, "download", o.ObjectName())(&runTime, &err)
+
+	start := time.Now()
+	defer func() {
+		runTime = time.Since(start)
+	}()
+
+	n, err = readRange(ctx, o, 0, -1)
+	return
+}
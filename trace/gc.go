@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// applyGOGC sets the GC percent from -gogc, which takes the same values as
+// the GOGC environment variable, and returns the effective setting.
+func applyGOGC() (string, error) {
+	if *gogc != "" {
+		pct := -1
+		if *gogc != "off" {
+			n, err := strconv.Atoi(*gogc)
+			if err != nil {
+				return "", fmt.Errorf("invalid -gogc %q: want a percentage or \"off\"", *gogc)
+			}
+			pct = n
+		}
+		debug.SetGCPercent(pct)
+	}
+	// SetGCPercent is the only way to read the current value back.
+	pct := debug.SetGCPercent(100)
+	debug.SetGCPercent(pct)
+	if pct < 0 {
+		return "off", nil
+	}
+	return strconv.Itoa(pct), nil
+}
+
+// gcReport summarises the garbage collector's work over the run.
+type gcReport struct {
+	GOGC       string  `json:"gogc"`
+	Cycles     uint32  `json:"cycles"`
+	PauseTotal float64 `json:"pause_total_ms"`
+	CPUPercent float64 `json:"cpu_percent"`
+}
+
+func readGCReport(gogc string) gcReport {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return gcReport{
+		GOGC:       gogc,
+		Cycles:     m.NumGC,
+		PauseTotal: float64(m.PauseTotalNs) / float64(time.Millisecond),
+		CPUPercent: 100 * m.GCCPUFraction,
+	}
+}
+
+func (g gcReport) String() string {
+	return fmt.Sprintf("GOGC=%s, %d cycles, %.2fms total pause, %.2f%% of CPU", g.GOGC, g.Cycles, g.PauseTotal, g.CPUPercent)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// goroutines watches the goroutine count under -max-goroutines.
+var goroutines *goroutineWatch
+
+// goroutineWatch samples runtime.NumGoroutine every interval and keeps the
+// peak. Going over limit means a leak or unbounded spawning, so the run is
+// aborted with the goroutine stacks, before it runs out of memory.
+type goroutineWatch struct {
+	stop chan struct{}
+	once sync.Once
+	done sync.WaitGroup
+	peak atomic.Int64
+}
+
+func watchGoroutines(limit int, interval time.Duration) *goroutineWatch {
+	g := &goroutineWatch{stop: make(chan struct{})}
+	g.done.Add(1)
+	go func() {
+		defer g.done.Done()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			n := runtime.NumGoroutine()
+			if int64(n) > g.peak.Load() {
+				g.peak.Store(int64(n))
+			}
+			if n > limit {
+				log.Printf("%d goroutines exceed -max-goroutines %d; stacks follow", n, limit)
+				pprof.Lookup("goroutine").WriteTo(os.Stderr, 1)
+				log.Fatalf("aborting: %d goroutines exceed -max-goroutines %d", n, limit)
+			}
+			select {
+			case <-g.stop:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	return g
+}
+
+// end stops sampling and returns the peak goroutine count. It may be called
+// more than once.
+func (g *goroutineWatch) end() int64 {
+	g.once.Do(func() { close(g.stop) })
+	g.done.Wait()
+	return g.peak.Load()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+)
+
+// The limits and defaults net/http applies to the HTTP/2 client settings;
+// a value outside the limits is replaced by the default.
+const (
+	h2MinFrameSize     = 16 << 10
+	h2MaxFrameSize     = 16 << 20
+	h2DefaultFrameSize = 1 << 20
+	h2MinConnWindow    = 65535
+	h2DefaultConnFlow  = 1 << 30
+	h2DefaultStream    = 4 << 20
+)
+
+// h2WindowsSet reports whether any HTTP/2 flow control flag was given.
+func h2WindowsSet() bool {
+	return *h2MaxReadFrameSize > 0 || *h2ConnWindow > 0 || *h2StreamWindow > 0
+}
+
+// h2Config returns the HTTP/2 settings for the http2 base transport from
+// -h2-max-read-frame-size, -h2-conn-window and -h2-stream-window.
+func h2Config() *http.HTTP2Config {
+	return &http.HTTP2Config{
+		MaxReadFrameSize:              int(*h2MaxReadFrameSize),
+		MaxReceiveBufferPerConnection: int(*h2ConnWindow),
+		MaxReceiveBufferPerStream:     int(*h2StreamWindow),
+	}
+}
+
+// effective mirrors how net/http resolves a setting: v if within
+// [lo, hi], else def.
+func effective(v, lo, hi, def int64) (int64, string) {
+	if v < lo || v > hi {
+		if v != 0 {
+			return def, fmt.Sprintf(" (default; %d is out of range)", v)
+		}
+		return def, " (default)"
+	}
+	return v, ""
+}
+
+// reportH2Windows prints the HTTP/2 frame and window sizes the http2
+// transport runs with.
+func reportH2Windows() {
+	frame, fNote := effective(int64(*h2MaxReadFrameSize), h2MinFrameSize, h2MaxFrameSize, h2DefaultFrameSize)
+	conn, cNote := effective(int64(*h2ConnWindow), h2MinConnWindow, math.MaxInt32, h2DefaultConnFlow)
+	stream, sNote := effective(int64(*h2StreamWindow), 1, math.MaxInt32, h2DefaultStream)
+	fmt.Printf("http2: max read frame size %d%s, connection window %d%s, stream window %d%s\n",
+		frame, fNote, conn, cNote, stream, sNote)
+}
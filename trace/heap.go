@@ -0,0 +1,46 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// heapWatch samples runtime.MemStats.HeapInuse every interval until stopped
+// and keeps the peak. The writer buffers at most one -chunk-size chunk, so
+// an upload's peak should stay at a few chunks however large the object.
+type heapWatch struct {
+	stop chan struct{}
+	once sync.Once
+	done sync.WaitGroup
+	peak uint64
+}
+
+func watchHeap(interval time.Duration) *heapWatch {
+	h := &heapWatch{stop: make(chan struct{})}
+	h.done.Add(1)
+	go func() {
+		defer h.done.Done()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		var ms runtime.MemStats
+		for {
+			runtime.ReadMemStats(&ms)
+			h.peak = max(h.peak, ms.HeapInuse)
+			select {
+			case <-h.stop:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	return h
+}
+
+// end stops sampling and returns the peak HeapInuse. It may be called more
+// than once.
+func (h *heapWatch) end() uint64 {
+	h.once.Do(func() { close(h.stop) })
+	h.done.Wait()
+	return h.peak
+}
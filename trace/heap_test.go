@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestWriteObjectHeapBound uploads an object many times the chunk size and
+// checks the writer's peak HeapInuse stays within -max-heap, so a change that
+// buffers the whole object fails here rather than in a large run.
+func TestWriteObjectHeapBound(t *testing.T) {
+	b := testbenchBucket(t)
+	const chunk = 1 << 20
+	setFlag(t, "chunk-size", "1MiB")
+	setFlag(t, "max-heap", "64MiB")
+
+	ctx := context.Background()
+	o := b.Object("heap-bound")
+	if _, err := writeObject(ctx, o, 256*chunk); err != nil {
+		t.Fatalf("writeObject: %v", err)
+	}
+	o.Delete(ctx)
+}
+
+// TestWriteObjectHeapExceededRecorded checks an upload that breaks -max-heap
+// is still recorded for -cleanup.
+func TestWriteObjectHeapExceededRecorded(t *testing.T) {
+	b := testbenchBucket(t)
+	setFlag(t, "chunk-size", "1MiB")
+	setFlag(t, "max-heap", "1")
+
+	ctx := context.Background()
+	o := b.Object("heap-exceeded")
+	_, err := writeObject(ctx, o, 4<<20)
+	if err == nil || !strings.Contains(err.Error(), "exceeds -max-heap") {
+		t.Fatalf("writeObject err = %v, want -max-heap failure", err)
+	}
+	created.mu.Lock()
+	objs := created.objs
+	created.mu.Unlock()
+	found := false
+	for _, c := range objs {
+		found = found || c.name == "heap-exceeded"
+	}
+	if !found {
+		t.Errorf("heap-exceeded not recorded for cleanup; recorded %v", objs)
+	}
+	o.Delete(ctx)
+}
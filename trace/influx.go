@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// influxBatchSize is how many lines are buffered before a write.
+const influxBatchSize = 100
+
+// influxWriter sends each recorded result as an InfluxDB line protocol point
+// to a file and/or a write endpoint, in batches. Write errors are logged and
+// never fail the run.
+type influxWriter struct {
+	file io.WriteCloser
+	url  string
+
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	lines  int
+	errors int
+}
+
+func newInfluxWriter(path, url string) (*influxWriter, error) {
+	w := &influxWriter{url: url}
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		w.file = f
+	}
+	return w, nil
+}
+
+var influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// add is a summary subscriber.
+func (w *influxWriter) add(r opResult) {
+	if r.Err != "" {
+		return
+	}
+	line := fmt.Sprintf("gcs_bench,api=%s,op=%s iteration=%di,bytes=%di,duration=%f,throughput=%f %d\n",
+		influxTagEscaper.Replace(*api), influxTagEscaper.Replace(r.Name),
+		r.Iteration, r.Bytes, r.DurationMS, mibps(r.Bytes, r.Duration), r.End.UnixNano())
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.WriteString(line)
+	w.lines++
+	if w.lines >= influxBatchSize {
+		w.flushLocked()
+	}
+}
+
+func (w *influxWriter) flushLocked() {
+	if w.lines == 0 {
+		return
+	}
+	batch := w.buf.Bytes()
+	if w.file != nil {
+		if _, err := w.file.Write(batch); err != nil {
+			w.errors++
+			log.Printf("influx: write file: %v", err)
+		}
+	}
+	if w.url != "" {
+		if err := w.post(batch); err != nil {
+			w.errors++
+			log.Printf("influx: send %d lines: %v", w.lines, err)
+		}
+	}
+	w.buf.Reset()
+	w.lines = 0
+}
+
+func (w *influxWriter) post(batch []byte) error {
+	resp, err := http.Post(w.url, "text/plain; charset=utf-8", bytes.NewReader(batch))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// close flushes any buffered lines and reports how many writes failed.
+func (w *influxWriter) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushLocked()
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			w.errors++
+			log.Printf("influx: close file: %v", err)
+		}
+	}
+	if w.errors > 0 {
+		fmt.Printf("influx: %d batch writes failed\n", w.errors)
+	}
+}
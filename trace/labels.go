@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// requestLabelPrefix is the header prefix GCS records in its audit logs.
+const requestLabelPrefix = "x-goog-custom-audit-"
+
+// requestLabelHeaders turns -request-label pairs into header name/value
+// pairs for callctx.SetHeaders, which the client sends as HTTP headers or
+// gRPC metadata. Keys must be valid in both: lowercase letters, digits, '-',
+// '_' and '.'. Values must be printable ASCII.
+func requestLabelHeaders(s string) ([]string, error) {
+	kv, err := parseKV(s)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var headers []string
+	for _, k := range keys {
+		if k == "" || strings.IndexFunc(k, func(r rune) bool {
+			return !('a' <= r && r <= 'z' || '0' <= r && r <= '9' || r == '-' || r == '_' || r == '.')
+		}) >= 0 {
+			return nil, fmt.Errorf("invalid label key %q: want lowercase letters, digits, '-', '_' or '.'", k)
+		}
+		v := kv[k]
+		if strings.IndexFunc(v, func(r rune) bool { return r < 0x20 || r > 0x7e }) >= 0 {
+			return nil, fmt.Errorf("invalid value for label %q: want printable ASCII", k)
+		}
+		headers = append(headers, requestLabelPrefix+k, v)
+	}
+	return headers, nil
+}
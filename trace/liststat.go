@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// listStat reproduces `ls -l` on a FUSE mount: a list of -prefix followed by
+// a stat of every returned object, the stats fanned out over -concurrency
+// workers.
+func listStat(ctx context.Context) error {
+	start := time.Now()
+	names, err := listNames(ctx, *prefix, 0)
+	if err != nil {
+		results.fail("list", time.Since(start), err)
+		return err
+	}
+	listTime := time.Since(start)
+	results.record("list", listTime, 0)
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+	)
+	statStart := time.Now()
+	err = forEach(names, *concurrency, func(worker int, name string) error {
+		t := time.Now()
+		if _, err := metadataClient().Bucket(*bucketFlag).Object(name).Attrs(ctx); err != nil {
+			results.failOn(worker, "stat", time.Since(t), err)
+			return fmt.Errorf("Attrs(%q): %w", name, err)
+		}
+		d := time.Since(t)
+		results.recordOn(worker, "stat", d, 0)
+		mu.Lock()
+		latencies = append(latencies, d)
+		mu.Unlock()
+		return nil
+	})
+	statTime := time.Since(statStart)
+
+	fmt.Printf("list %q: %d objects in %v\n", *prefix, len(names), listTime)
+	fmt.Printf("stat fan-out with %d workers: %v total, latency %s\n", *concurrency, statTime, latencySummary(latencies))
+	return err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// listPageSizeSweep lists -prefix (within -start-offset and -end-offset)
+// once per -page-sizes entry, -iterations times each, fetching a page per
+// round trip, and prints the list latency and round trips at each page size.
+func listPageSizeSweep(ctx context.Context) error {
+	var sizes []int
+	for _, f := range strings.Split(*pageSizes, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid -page-sizes entry %q", f)
+		}
+		sizes = append(sizes, n)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "page size\tobjects\tround trips\tn\tp50\tp90\tp99\tmax")
+	for _, size := range sizes {
+		var (
+			latencies           []time.Duration
+			objects, roundTrips int
+		)
+		for i := range max(*iterations, 1) {
+			results.setIteration(i)
+			start := time.Now()
+			n, pages, err := listPaged(ctx, size)
+			if err != nil {
+				results.fail(fmt.Sprintf("list/%d", size), time.Since(start), err)
+				return fmt.Errorf("page size %d: %w", size, err)
+			}
+			d := time.Since(start)
+			latencies = append(latencies, d)
+			results.record(fmt.Sprintf("list/%d", size), d, 0)
+			objects, roundTrips = n, pages
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%s\n", size, objects, roundTrips, latencyColumns(latencies))
+	}
+	return tw.Flush()
+}
+
+// listPaged lists the -prefix range pageSize objects per request and returns
+// the objects and pages fetched.
+func listPaged(ctx context.Context, pageSize int) (objects, pages int, err error) {
+	q := &storage.Query{Prefix: *prefix, StartOffset: *startOffset, EndOffset: *endOffset}
+	it := metadataClient().Bucket(*bucketFlag).Objects(ctx, q)
+	p := iterator.NewPager(it, pageSize, "")
+	for {
+		var page []*storage.ObjectAttrs
+		token, err := p.NextPage(&page)
+		if err != nil {
+			return objects, pages, fmt.Errorf("Bucket(%q).Objects: %w", *bucketFlag, err)
+		}
+		pages++
+		objects += len(page)
+		if token == "" {
+			return objects, pages, nil
+		}
+	}
+}
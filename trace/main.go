@@ -2,8 +2,9 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
 	"errors"
 	"flag"
 	"fmt"
@@ -12,6 +13,9 @@ import (
 	"net/http"
 	"os"
 	"runtime/pprof"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"cloud.google.com/go/storage"
@@ -21,24 +25,149 @@ import (
 	htransport "google.golang.org/api/transport/http"
 
 	texporter "github.com/GoogleCloudPlatform/opentelemetry-operations-go/exporter/trace"
-	"github.com/google/uuid"
+	"github.com/googleapis/gax-go/v2/callctx"
 	"go.opentelemetry.io/contrib/detectors/gcp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
 	_ "google.golang.org/grpc/balancer/rls"
 	_ "google.golang.org/grpc/xds/googledirectpath"
 )
 
 var (
-	bucketFlag = flag.String("bucket", "mhall-golang-test", "bucket")
-	api        = flag.String("api", "http2", "api; http1, http2, grpc-dp")
-	cpuprofile = flag.String("cpuprofile", "", "write cpu profile to `file`")
-	addSpans   = flag.Bool("add-spans", false, "wrap ops with app level spans")
-	client     *storage.Client
+	bucketFlag             = flag.String("bucket", "mhall-golang-test", "bucket")
+	api                    = flag.String("api", "http2", "api; http1, http2, grpc-dp")
+	cpuprofile             = flag.String("cpuprofile", "", "write cpu profile to `file`")
+	addSpans               = flag.Bool("add-spans", false, "wrap ops with app level spans")
+	op                     = flag.String("op", opUploadDownload, "operation; upload-download, download, list, list-stat, fan-read, seek-read, probe, update-metadata, churn, concurrent-append, retry-check, seed, meta-compare, resumable-overhead, list-pagesize-sweep, prefetch-read, small-writes, restore-bench, noop, deadline-check, migrate, raw-consistency, scenario-script")
+	maxBytes               = sizeFlag("max-bytes", 0, "stop the run once this many bytes have been uploaded+downloaded; 0 for no limit")
+	startOffset            = flag.String("start-offset", "", "list only objects whose names are lexicographically >= this")
+	endOffset              = flag.String("end-offset", "", "list only objects whose names are lexicographically < this")
+	noChecksum             = flag.Bool("no-checksum", false, "don't send CRC32C or MD5 checksums with uploads")
+	prefix                 = flag.String("prefix", "", "object name prefix for multi-object operations")
+	fanout                 = flag.Int("fanout", 16, "number of distinct objects to read in fan-read")
+	concurrency            = flag.Int("concurrency", 4, "number of concurrent workers")
+	slowThreshold          = flag.Duration("slow-threshold", 0, "only export traces whose root span took at least this long; 0 exports all")
+	downscopePrefix        = flag.String("downscope-prefix", "", "use a downscoped token limited to objects in -bucket with this prefix")
+	objectSize             = sizeFlag("object-size", 10*1024*1024, "size of the uploaded object")
+	chunkSize              = sizeFlag("chunk-size", 16*1024*1024, "writer chunk size; 0 uploads in a single request")
+	resumableThreshold     = sizeFlag("resumable-threshold", 0, "upload objects smaller than this in one shot and larger ones resumably; 0 leaves it to -chunk-size")
+	connPool               = flag.Int("conn-pool", 0, "gRPC connection pool size, or max idle connections per host for http1/http2; 0 for the default")
+	readBuffer             = sizeFlag("read-buffer", 0, "transport read buffer size; 0 for the default")
+	writeBuffer            = sizeFlag("write-buffer", 0, "transport write buffer size; 0 for the default")
+	profile                = flag.String("profile", "", "tuning profile; low-latency, balanced, high-throughput")
+	chunkStall             = flag.Duration("chunk-stall", 0, "report upload chunks that take longer than this; 0 disables")
+	requireLocation        = flag.String("require-location", "", "fail before running if -bucket is not in this location, e.g. us-central1")
+	seeks                  = flag.Int("seeks", 8, "number of scattered reads in seek-read")
+	seekReadSize           = sizeFlag("seek-read-size", 64*1024, "bytes read at each offset in seek-read")
+	tracerName             = flag.String("tracer-name", "github.com/madisonhall38/go-scripts/trace", "instrumentation scope name for app level spans")
+	objectFlag             = flag.String("object", "", "name of an existing object to operate on")
+	duration               = flag.Duration("duration", time.Minute, "how long to run time-bounded operations such as probe")
+	probeInterval          = flag.Duration("probe-interval", time.Second, "time between probe reads")
+	minAvailability        = flag.Float64("min-availability", 0, "exit non-zero if probe availability (percent) falls below this")
+	caCert                 = flag.String("ca-cert", "", "PEM `file` of CA certificates to trust on the http1/http2 transports")
+	recordSpans            = flag.String("record-spans", "", "also write every span produced to `file` as JSON lines")
+	validateSpansFile      = flag.String("validate-spans", "", "check the spans in a -record-spans `file` against the expected shape and exit; needs no network")
+	cleanupFlag            = flag.Bool("cleanup", false, "delete the objects the run uploaded once it finishes")
+	connStatsFlag          = flag.Bool("conn-stats", false, "count and report how many requests reused a connection")
+	ttlLabel               = flag.String("ttl-label", "", "`key=value` metadata set on every uploaded object for a bucket lifecycle rule to reap, e.g. autodelete=true")
+	readCompressed         = flag.Bool("read-compressed", false, "read gzip-encoded objects as stored, without decompressive transcoding")
+	gogc                   = flag.String("gogc", "", "set the GC percent, as the GOGC environment variable does; a percentage or \"off\"")
+	exportConcurrency      = flag.Int("export-concurrency", 1, "number of span batch processors, each with its own trace exporter")
+	traceparent            = flag.String("traceparent", "", "W3C traceparent header to nest this run's spans under an external trace")
+	storageClass           = flag.String("storage-class", "", "storage class for uploaded objects; STANDARD, NEARLINE, COLDLINE, ARCHIVE")
+	pprofAddr              = flag.String("pprof-addr", "", "serve net/http/pprof on this `address` for the duration of the run")
+	iterations             = flag.Int("iterations", 1, "number of rounds for upload-download, update-metadata, concurrent-append, resumable-overhead, list-pagesize-sweep, noop, deadline-check and raw-consistency")
+	sizeRamp               = flag.String("size-ramp", "", "step the object size each upload-download iteration, e.g. \"1MiB..1GiB x2\" or \"1MiB..8MiB +1MiB\"; overrides -iterations and -object-size")
+	metadataFlag           = flag.String("metadata", "", "comma separated `key=value` pairs for update-metadata")
+	ifMetagenMatch         = flag.Int64("if-metageneration-match", 0, "make update-metadata conditional on this metageneration")
+	influxOut              = flag.String("influx-out", "", "write each result as an InfluxDB line protocol point to `file`")
+	influxURL              = flag.String("influx-url", "", "also send line protocol points to this InfluxDB write `url`")
+	separateIOContext      = flag.Bool("separate-io-context", false, "open the download reader with its own span-free context rather than the user span's")
+	disableClientMetrics   = flag.Bool("disable-client-metrics", false, "turn off the gRPC client's built-in metrics export")
+	cacheReads             = flag.Bool("cache-reads", false, "serve repeated reads of the same object range from an in-process LRU cache")
+	cacheSize              = sizeFlag("cache-size", 256*1024*1024, "capacity of the -cache-reads cache")
+	churnCount             = flag.Int("churn-count", 10, "number of overwrites in churn")
+	churnPrecondition      = flag.Bool("churn-precondition", false, "make each churn overwrite conditional on the previous generation")
+	connectTimeout         = flag.Duration("connect-timeout", 0, "bound each transport dial; 0 for no limit")
+	readTimeout            = flag.Duration("read-timeout", 0, "fail a read that gets no data for this long; 0 for no limit")
+	overallTimeout         = flag.Duration("overall-timeout", 0, "bound the whole run; 0 for no limit")
+	seed                   = flag.Uint64("seed", 0, "upload a deterministic payload generated from this seed; 0 uploads random bytes")
+	sendCRC32CFlag         = flag.String("send-crc32c", "", "send this CRC32C (hex, decimal, or auto with -seed) with uploads so the server rejects a mismatched body")
+	appendSize             = sizeFlag("append-size", 1024*1024, "bytes each writer appends per append in concurrent-append")
+	samplePerOp            = flag.String("sample-per-op", "", "sample root spans by operation, e.g. upload=1.0,download=0.1,list=0.5; unlisted operations are always sampled")
+	logEndpoint            = flag.Bool("log-endpoint", false, "log the endpoint the client resolved to and whether DirectPath is in use")
+	endpointManifest       = flag.String("endpoint-manifest", "", "`file` of \"PREFIX ENDPOINT\" lines routing fan-read objects to per-endpoint clients")
+	seedCount              = flag.Int("seed-count", 100, "number of objects seed uploads")
+	seedRetries            = flag.Int("seed-retries", 3, "times seed retries an object under a new name when its name is taken")
+	noClobber              = flag.Bool("no-clobber", false, "skip objects whose name is taken in seed rather than retrying")
+	downloadTo             = flag.String("download-to", "", "write downloaded bytes to this `path`, or to files named after the objects if it is a directory, instead of discarding them")
+	fsyncDownloads         = flag.Bool("fsync", false, "fsync each -download-to file after writing it and time the sync separately")
+	metaCalls              = flag.Int("meta-calls", 20, "number of Attrs and Update calls per client in meta-compare")
+	peerIPFlag             = flag.Bool("peer-ip", false, "tag gRPC spans with the peer IP and report how traffic spread across peers")
+	maxHeap                = sizeFlag("max-heap", 0, "sample the heap during uploads, report its peak and fail an upload whose HeapInuse exceeds this; 0 disables")
+	scenarioFlag           = flag.String("scenario", "", "run a named GCSFuse workload; sequential-read, random-read, write-heavy, ls-l, open-close-churn")
+	excludeColdStart       = flag.Bool("exclude-cold-start", false, "leave iteration 0 out of the steady state upload and download latency")
+	otelLogs               = flag.Bool("otel-logs", false, "emit an OTLP log record, correlated with its trace, as each operation starts and ends")
+	overlap                = flag.Bool("overlap", false, "in upload-download, download each object while the next iteration uploads")
+	requestLabel           = flag.String("request-label", "", "comma separated `key=value` pairs sent on every request as x-goog-custom-audit-KEY headers")
+	validateRepro          = flag.Bool("validate-reproduction", false, "run the upload-download reproduction, e.g. against the testbench, and fail unless its spans have the expected parents, timing and attributes")
+	resultsObject          = flag.String("results-object", "", "also upload the JSON summary to this gs://BUCKET/OBJECT `url` after the run")
+	noContentTypeSniff     = flag.Bool("no-content-type-sniff", false, "upload as application/octet-stream rather than letting the writer sniff the content type")
+	pageSizes              = flag.String("page-sizes", "100,500,1000,5000", "comma separated page sizes for list-pagesize-sweep")
+	tracingOptional        = flag.Bool("tracing-optional", false, "if the trace exporter can't be created, warn and run without tracing rather than exiting")
+	spanDepth              = flag.Int("span-depth", 0, "nest each upload, download, list and read under a chain of this many decoy spans and report the deepest span")
+	prefetchWindows        = flag.Int("prefetch-windows", 2, "windows prefetch-read keeps in flight ahead of the reader")
+	windowSize             = sizeFlag("window-size", 8*1024*1024, "bytes per range read in prefetch-read")
+	writeUnit              = sizeFlag("write-unit", 64*1024, "size of each write in small-writes")
+	flushWrites            = flag.Bool("flush-writes", false, "in small-writes on grpc-dp, append and flush after each write instead of relying on chunk boundaries")
+	predefinedACL          = flag.String("predefined-acl", "", "predefined ACL for uploaded objects, e.g. projectPrivate, publicRead, bucketOwnerFullControl; not allowed with uniform bucket-level access")
+	streamEvents           = flag.String("stream-events", "", "write each completed or failed op as a JSON line {ts, iteration, op, bytes, duration_ms, err} to `file` as it happens")
+	proxyFlag              = flag.String("proxy", "", "http, https or socks5 proxy URL for both HTTP and gRPC; by default HTTP_PROXY, HTTPS_PROXY and NO_PROXY are honoured")
+	wireBytes              = flag.Bool("wire-bytes", false, "count bytes on the wire and report their overhead over the payload uploaded and downloaded")
+	count                  = flag.Int("count", 100, "number of objects restore-bench soft-deletes and restores")
+	placement              = flag.String("placement", "", "object naming for upload and seed: sequential (monotonically increasing, the index hotspot worst case) or random (uuid); by default uploads are random and seed names are sequential")
+	reportMD               = flag.String("report-md", "", "write a markdown report of the run (configuration, latency and throughput tables, trace link) to this file")
+	encryptionKeyFlag      = flag.String("encryption-key", "", "base64 AES-256 customer-supplied encryption key for upload-download and download")
+	decryptionKeyFlag      = flag.String("decryption-key", "", "base64 AES-256 key upload-download writes under before rotating each object to -encryption-key with a rewrite and reading it back")
+	maxGoroutines          = flag.Int("max-goroutines", 0, "sample the goroutine count, report its peak and abort with the goroutine stacks if it exceeds this; 0 disables")
+	deadline               = flag.Duration("deadline", 100*time.Millisecond, "deadline of each read in deadline-check")
+	deadlineTolerance      = flag.Duration("deadline-tolerance", 200*time.Millisecond, "how long after its deadline a read in deadline-check may take to return")
+	dumpResource           = flag.Bool("dump-resource", false, "print the resolved OpenTelemetry resource attributes once at startup, marking detected and custom ones")
+	srcBucket              = flag.String("src-bucket", "", "bucket migrate copies from; defaults to -bucket")
+	dstBucket              = flag.String("dst-bucket", "", "bucket migrate copies to")
+	migrateMove            = flag.Bool("migrate-move", false, "in migrate, delete each source object once it is copied")
+	assertGRPCUpload       = flag.Bool("assert-grpc-upload", false, "on grpc-dp, report the protocol each upload used and fail an upload that fell back to JSON/HTTP")
+	tlsMinVersionFlag      = flag.String("tls-min-version", "", "minimum TLS version for http1 and http2: 1.0, 1.1, 1.2 or 1.3")
+	tlsCiphersFlag         = flag.String("tls-ciphers", "", "comma separated TLS 1.2 cipher suites http1 and http2 may negotiate, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256; connections that negotiate TLS 1.3 use its fixed suites")
+	targetCI               = flag.Float64("target-ci", 0, "run upload-download iterations until the 95% confidence interval of the mean upload and download latency is within this percentage of the mean; overrides -iterations")
+	maxIterations          = flag.Int("max-iterations", 100, "most upload-download iterations -target-ci runs")
+	transform              = flag.String("transform", "", "pass the upload payload through none, gzip or encrypt (AES-256-CTR) in flight and report the bytes in and out")
+	timelineOut            = flag.String("timeline-out", "", "write each op's start and end, relative to the run start, and worker as JSON for a Gantt chart to this file")
+	separateMetadataClient = flag.Bool("separate-metadata-client", false, "send Attrs, list and stat calls through a second client with its own connection pool and report connection use per client")
+	h2MaxReadFrameSize     = sizeFlag("h2-max-read-frame-size", 0, "largest HTTP/2 frame the http2 transport accepts, 16KiB to 16MiB; 0 uses the default")
+	h2ConnWindow           = sizeFlag("h2-conn-window", 0, "HTTP/2 connection flow control window for http2; 0 uses the default")
+	h2StreamWindow         = sizeFlag("h2-stream-window", 0, "HTTP/2 per-stream flow control window for http2; 0 uses the default")
+	scenarioScript         = flag.String("scenario-script", "", "`file` of \"VERB ARGS\" steps (mkdir, upload, list, read, rename, delete) to run as one trace with a span per step; implies -op scenario-script")
+	summaryOut             = flag.String("summary-out", "", "write a JSON summary of the run, including its configuration, to `file`")
+	configOut              = flag.String("config-out", "", "write the effective configuration as JSON to `file`")
+	client                 *storage.Client
+	budget                 *transferBudget
+	results                = &summary{}
+	// runErr fails the run after the results have been reported.
+	runErr error
+	// gcPercent is the effective GOGC setting.
+	gcPercent string
 )
 
 const (
@@ -47,17 +176,205 @@ const (
 	dp    = "grpc-dp"
 )
 
+const (
+	// downloadSize is the most bytes read back by download.
+	downloadSize = 1024 * 1024
+)
+
+const (
+	opUploadDownload    = "upload-download"
+	opDownload          = "download"
+	opList              = "list"
+	opListStat          = "list-stat"
+	opFanRead           = "fan-read"
+	opSeekRead          = "seek-read"
+	opProbe             = "probe"
+	opUpdateMetadata    = "update-metadata"
+	opChurn             = "churn"
+	opConcurrentAppend  = "concurrent-append"
+	opRetryCheck        = "retry-check"
+	opSeed              = "seed"
+	opMetaCompare       = "meta-compare"
+	opResumableOverhead = "resumable-overhead"
+	opListPageSizeSweep = "list-pagesize-sweep"
+	opPrefetchRead      = "prefetch-read"
+	opSmallWrites       = "small-writes"
+	opRestoreBench      = "restore-bench"
+	opNoop              = "noop"
+	opDeadlineCheck     = "deadline-check"
+	opMigrate           = "migrate"
+	opRawConsistency    = "raw-consistency"
+	opScenarioScript    = "scenario-script"
+)
+
 func main() {
-	ctx := context.Background()
+	// Fail the run only once the deferred flushes below have run.
+	defer func() {
+		if runErr != nil {
+			log.Fatalln(runErr)
+		}
+	}()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
 	flag.Parse()
+	if *validateSpansFile != "" {
+		runErr = validateSpans(*validateSpansFile)
+		return
+	}
+	if err := validateAPI(); err != nil {
+		log.Fatalln(err)
+	}
+	if *validateRepro && *op != opUploadDownload {
+		log.Fatalf("-validate-reproduction runs -op %s, not %s", opUploadDownload, *op)
+	}
+	applyScenario()
+	applyProfile()
+	if *noChecksum && *sendCRC32CFlag != "" {
+		log.Fatalln("-no-checksum and -send-crc32c are mutually exclusive")
+	}
+	if *proxyFlag != "" {
+		u, err := parseProxy(*proxyFlag)
+		if err != nil {
+			log.Fatalf("-proxy: %v", err)
+		}
+		proxyURL = u
+	}
+	if *assertGRPCUpload && *api != dp {
+		log.Fatalln("-assert-grpc-upload needs -api grpc-dp")
+	}
+	if h2WindowsSet() {
+		if *api != http2 {
+			log.Fatalln("-h2-max-read-frame-size, -h2-conn-window and -h2-stream-window need -api http2")
+		}
+		reportH2Windows()
+	}
+	if *targetCI > 0 && (*sizeRamp != "" || *overlap) {
+		log.Fatalln("-target-ci can't be combined with -size-ramp or -overlap")
+	}
+	if *downloadTo != "" && *op == opFanRead {
+		// Every object would otherwise be written over the same file.
+		if fi, err := os.Stat(*downloadTo); err != nil || !fi.IsDir() {
+			log.Fatalf("-op %s reads many objects, so -download-to must be an existing directory", opFanRead)
+		}
+	}
+	if err := validateTransform(); err != nil {
+		log.Fatalln(err)
+	}
+	if err := parseTLSFlags(); err != nil {
+		log.Fatalln(err)
+	}
+	if err := parseKeys(); err != nil {
+		log.Fatalln(err)
+	}
+	if err := validatePlacement(); err != nil {
+		log.Fatalln(err)
+	}
+	if err := validatePredefinedACL(); err != nil {
+		log.Fatalln(err)
+	}
+	if *resultsObject != "" {
+		if _, _, err := parseGCSURL(*resultsObject); err != nil {
+			log.Fatalf("-results-object: %v", err)
+		}
+	}
+	switch strings.ToUpper(*storageClass) {
+	case "", "STANDARD", "NEARLINE", "COLDLINE", "ARCHIVE":
+	default:
+		log.Fatalf("invalid -storage-class %q", *storageClass)
+	}
+	var err error
+	if gcPercent, err = applyGOGC(); err != nil {
+		log.Fatalln(err)
+	}
+	if *overallTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, *overallTimeout, errOverallTimeout)
+		defer cancelTimeout()
+		watchOverallTimeout(ctx)
+	}
+	if *requestLabel != "" {
+		headers, err := requestLabelHeaders(*requestLabel)
+		if err != nil {
+			log.Fatalf("-request-label: %v", err)
+		}
+		ctx = callctx.SetHeaders(ctx, headers...)
+	}
+	budget = newTransferBudget(int64(*maxBytes), cancel)
+	if *cacheReads {
+		reads = newReadCache(int64(*cacheSize))
+	}
+	if *scenarioScript != "" {
+		if *op != opUploadDownload && *op != opScenarioScript {
+			log.Fatalf("-scenario-script can't be combined with -op %s", *op)
+		}
+		*op = opScenarioScript
+		if script, err = loadScript(*scenarioScript); err != nil {
+			log.Fatalf("-scenario-script: %v", err)
+		}
+	} else if *op == opScenarioScript {
+		log.Fatalln("-op scenario-script requires -scenario-script")
+	}
+	if *endpointManifest != "" {
+		if endpoints, err = loadEndpointManifest(*endpointManifest); err != nil {
+			log.Fatalf("-endpoint-manifest: %v", err)
+		}
+	}
+	if *op == opRetryCheck {
+		faults = &faultInjector{}
+	}
+	if *op == opResumableOverhead || *op == opSmallWrites {
+		uploadRPCs = &uploadTimer{times: map[string][]time.Duration{}}
+	}
+	if *api == dp || *op == opMetaCompare || *op == opDeadlineCheck {
+		enableDirectPath()
+	}
 	client = getClient(ctx)
 	if client == nil {
 		log.Fatalln("client is nil")
 	}
+	if *separateMetadataClient {
+		metaClient = newClientConns(ctx, *api, &metaConns)
+	}
+	if *logEndpoint {
+		endpoint, directPath := clientEndpoint()
+		log.Printf("endpoint: %s (api %s, directpath %t)", endpoint, *api, directPath)
+	}
+
+	if *requireLocation != "" {
+		if err := checkLocation(ctx); err != nil {
+			log.Fatalf("location check: %v", err)
+		}
+	}
+
+	if *downscopePrefix != "" {
+		if err := checkDownscope(ctx); err != nil {
+			log.Fatalf("downscope check: %v", err)
+		}
+		fmt.Printf("downscoped to gs://%s/%s*: access outside the prefix is denied\n", *bucketFlag, *downscopePrefix)
+	}
+
+	// noop measures the tool without GCS calls.
+	if *op != opNoop {
+		logRetention(ctx)
+	}
 
 	close := enableTracing(ctx)
 	defer close()
 
+	if *traceparent != "" {
+		ctx = propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": *traceparent})
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			log.Fatalf("invalid -traceparent %q", *traceparent)
+		}
+	}
+	if _, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider); ok {
+		traceURL = traceConsoleURL(ctx)
+	}
+
+	if *pprofAddr != "" {
+		servePprof(ctx, *pprofAddr)
+	}
+
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {
@@ -71,59 +388,566 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	timetakenU, o, err := upload(ctx, *addSpans)
+	if *streamEvents != "" {
+		events, err := newEventStream(*streamEvents)
+		if err != nil {
+			log.Fatalf("-stream-events: %v", err)
+		}
+		results.subscribe(events.add)
+		defer events.close()
+	}
+	if *influxOut != "" || *influxURL != "" {
+		iw, err := newInfluxWriter(*influxOut, *influxURL)
+		if err != nil {
+			log.Fatalf("-influx-out: %v", err)
+		}
+		results.subscribe(iw.add)
+		defer iw.close()
+	}
+
+	if *maxGoroutines > 0 {
+		goroutines = watchGoroutines(*maxGoroutines, 100*time.Millisecond)
+	}
+
+	results.Op = *op
+	switch *op {
+	case opUploadDownload:
+		uploadDownload(ctx)
+	case opDownload:
+		downloadOnly(ctx)
+	case opList:
+		timetaken, count, err := listObjs(ctx, *addSpans)
+		if err != nil {
+			results.fail("list", timetaken, err)
+			log.Fatalf("list failed: %v\n", err)
+		}
+		results.record("list", timetaken, 0)
+		fmt.Printf("objects in range [%q, %q): %d\n", *startOffset, *endOffset, count)
+	case opListStat:
+		if err := listStat(ctx); err != nil {
+			log.Fatalf("list-stat failed: %v\n", err)
+		}
+	case opFanRead:
+		if err := fanRead(ctx, *addSpans); err != nil && !stopped(ctx) {
+			log.Fatalf("fan-read failed: %v\n", err)
+		}
+	case opSeekRead:
+		seekReadOp(ctx)
+	case opProbe:
+		runErr = probe(ctx, *addSpans)
+	case opUpdateMetadata:
+		if err := updateMetadata(ctx); err != nil {
+			log.Fatalf("update-metadata failed: %v\n", err)
+		}
+	case opChurn:
+		if err := churn(ctx); err != nil && !stopped(ctx) {
+			log.Fatalf("churn failed: %v\n", err)
+		}
+	case opConcurrentAppend:
+		if err := concurrentAppend(ctx); err != nil && !stopped(ctx) {
+			log.Fatalf("concurrent-append failed: %v\n", err)
+		}
+	case opRetryCheck:
+		if err := retryCheck(ctx); err != nil && !stopped(ctx) {
+			log.Fatalf("retry-check failed: %v\n", err)
+		}
+	case opSeed:
+		if err := seedObjects(ctx); err != nil && !stopped(ctx) {
+			log.Fatalf("seed failed: %v\n", err)
+		}
+	case opMetaCompare:
+		if err := metaCompare(ctx); err != nil {
+			log.Fatalf("meta-compare failed: %v\n", err)
+		}
+	case opResumableOverhead:
+		if err := resumableOverhead(ctx); err != nil && !stopped(ctx) {
+			log.Fatalf("resumable-overhead failed: %v\n", err)
+		}
+	case opListPageSizeSweep:
+		if err := listPageSizeSweep(ctx); err != nil {
+			log.Fatalf("list-pagesize-sweep failed: %v\n", err)
+		}
+	case opPrefetchRead:
+		if err := prefetchRead(ctx); err != nil && !stopped(ctx) {
+			log.Fatalf("prefetch-read failed: %v\n", err)
+		}
+	case opSmallWrites:
+		if err := smallWrites(ctx); err != nil && !stopped(ctx) {
+			log.Fatalf("small-writes failed: %v\n", err)
+		}
+	case opRestoreBench:
+		if err := restoreBench(ctx); err != nil && !stopped(ctx) {
+			log.Fatalf("restore-bench failed: %v\n", err)
+		}
+	case opNoop:
+		if err := noopOp(ctx); err != nil && !stopped(ctx) {
+			log.Fatalf("noop failed: %v\n", err)
+		}
+	case opDeadlineCheck:
+		if err := deadlineCheck(ctx); err != nil && !stopped(ctx) {
+			log.Fatalf("deadline-check failed: %v\n", err)
+		}
+	case opMigrate:
+		if err := migrate(ctx); err != nil && !stopped(ctx) {
+			log.Fatalf("migrate failed: %v\n", err)
+		}
+	case opRawConsistency:
+		if err := rawConsistency(ctx); err != nil && !stopped(ctx) {
+			log.Fatalf("raw-consistency failed: %v\n", err)
+		}
+	case opScenarioScript:
+		if err := runScript(ctx); err != nil && !stopped(ctx) {
+			log.Fatalf("scenario-script failed: %v\n", err)
+		}
+	default:
+		log.Fatalf("invalid -op %q", *op)
+	}
+
+	if *cleanupFlag {
+		// Clean up even if -max-bytes cancelled the run.
+		cleanup(context.WithoutCancel(ctx))
+	}
+
+	report(ctx)
+}
+
+// uploadDownload runs -iterations rounds of uploading a new object and then
+// running the download reproduction against it. With -size-ramp the object
+// size steps through the ramp instead, one iteration per size.
+func uploadDownload(ctx context.Context) {
+	sizes, err := iterationSizes()
 	if err != nil {
-		log.Fatalf("upload failed: %v\n", err)
+		log.Fatalln(err)
+	}
+	if *overlap {
+		uploadDownloadOverlapped(ctx, sizes)
+		return
 	}
+	var uploads, downloads []time.Duration
+	for i, size := range sizes {
+		results.setIteration(i)
+		timetakenU, o, err := upload(ctx, size, *addSpans)
+		if stopped(ctx) {
+			return
+		}
+		if err != nil {
+			failUpload(timetakenU, size, err)
+			log.Fatalf("upload failed: %v\n", err)
+		}
+		recordUpload(timetakenU, size)
+		if o, err = rotateKey(ctx, o); err != nil {
+			log.Fatalln(err)
+		}
+
+		length := min(downloadSize, size)
+		timetakenD, err := download(ctx, o, length, *addSpans)
+		if stopped(ctx) {
+			return
+		}
+		reportRotatedRead(err)
+		if err != nil {
+			results.fail("download", timetakenD, err)
+			log.Fatalf("download failed: %v\n", err)
+		}
+		results.record("download", timetakenD, length)
+
+		if *sizeRamp != "" {
+			fmt.Printf("size %d: upload %.2f MiB/s, download %.2f MiB/s\n",
+				size, mibps(size, timetakenU), mibps(length, timetakenD))
+		}
+		if *targetCI > 0 {
+			uploads, downloads = append(uploads, timetakenU), append(downloads, timetakenD)
+			if withinTargetCI(uploads) && withinTargetCI(downloads) {
+				reportTargetCI(uploads, downloads, true)
+				return
+			}
+		}
+	}
+	if *targetCI > 0 {
+		reportTargetCI(uploads, downloads, false)
+	}
+}
 
-	_ = o
+// uploadDownloadOverlapped is uploadDownload with -overlap: each iteration's
+// upload runs while the previous iteration's object is downloaded, as a
+// producer-consumer pipeline does. It reports the wall-clock time against
+// the time the same ops take back to back.
+func uploadDownloadOverlapped(ctx context.Context, sizes []int64) {
+	type written struct {
+		o         *storage.ObjectHandle
+		length    int64
+		iteration int
+	}
+	var (
+		prev   *written
+		serial time.Duration
+		start  = time.Now()
+	)
+	for i := 0; i <= len(sizes); i++ {
+		var (
+			wg    sync.WaitGroup
+			dTime time.Duration
+			dErr  error
+		)
+		if prev != nil {
+			p := prev
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				dTime, dErr = download(ctx, p.o, p.length, *addSpans)
+				reportRotatedRead(dErr)
+				// Downloads run as worker 1 beside the uploads on worker 0.
+				if dErr == nil {
+					results.recordAt(p.iteration, 1, "download", dTime, p.length)
+				} else {
+					results.failAt(p.iteration, 1, "download", dTime, dErr)
+				}
+			}()
+		}
 
-	timetakenC := time.Duration(0)
-	timetakenD := time.Duration(0)
+		prev = nil
+		if i < len(sizes) {
+			results.setIteration(i)
+			size := sizes[i]
+			timetakenU, o, err := upload(ctx, size, *addSpans)
+			if err != nil && !stopped(ctx) {
+				failUpload(timetakenU, size, err)
+				log.Fatalf("upload failed: %v\n", err)
+			}
+			if err == nil {
+				recordUpload(timetakenU, size)
+				serial += timetakenU
+				if o, err = rotateKey(ctx, o); err != nil {
+					log.Fatalln(err)
+				}
+				prev = &written{o: o, length: min(downloadSize, size), iteration: i}
+			}
+		}
 
-	timetakenD, err = download(ctx, o, *addSpans)
+		wg.Wait()
+		if stopped(ctx) {
+			return
+		}
+		if dErr != nil {
+			log.Fatalf("download failed: %v\n", dErr)
+		}
+		serial += dTime
+	}
+
+	wall := time.Since(start)
+	verdict := "no faster than"
+	if wall < serial {
+		verdict = fmt.Sprintf("%.2fx faster than", float64(serial)/float64(wall))
+	}
+	fmt.Printf("overlap: %v wall clock, %s the %v the same ops take back to back\n", wall, verdict, serial)
+}
+
+// iterationSizes returns the object size to upload in each iteration.
+func iterationSizes() ([]int64, error) {
+	if *sizeRamp != "" {
+		return parseSizeRamp(*sizeRamp)
+	}
+	n := *iterations
+	if *targetCI > 0 {
+		n = *maxIterations
+	}
+	sizes := make([]int64, max(n, 1))
+	for i := range sizes {
+		sizes[i] = int64(*objectSize)
+	}
+	return sizes, nil
+}
+
+// downloadOnly runs the download reproduction against the existing -object.
+func downloadOnly(ctx context.Context) {
+	if *objectFlag == "" {
+		log.Fatalln("-op download requires -object")
+	}
+	o := client.Bucket(*bucketFlag).Object(*objectFlag)
+	if encryptionKey != nil {
+		o = o.Key(encryptionKey)
+	}
+	if *readCompressed {
+		o = o.ReadCompressed(true)
+	}
+
+	// Fail early and clearly on a mistyped -object rather than deep in
+	// NewRangeReader.
+	attrs, err := metadataClient().Bucket(*bucketFlag).Object(*objectFlag).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		log.Fatalf("object %s not found in bucket %s", *objectFlag, *bucketFlag)
+	}
+	if err != nil {
+		log.Fatalf("Attrs: %v", err)
+	}
+
+	length := min(downloadSize, attrs.Size)
+	timetaken, err := download(ctx, o, length, *addSpans)
+	if stopped(ctx) {
+		return
+	}
 	if err != nil {
+		results.fail("download", timetaken, err)
 		log.Fatalf("download failed: %v\n", err)
 	}
+	results.record("download", timetaken, length)
 
-	// timetakenC, err := listObjs(ctx, *addSpans)
-	// if err != nil {
-	//      log.Fatalf("download failed: %v\n", err)
-	// }
+	if *readCompressed {
+		fmt.Printf("read compressed: %d bytes transferred; stored object is %d bytes (Content-Encoding %q)\n",
+			budget.transferred(), attrs.Size, attrs.ContentEncoding)
+		if attrs.ContentEncoding == "gzip" {
+			n, err := gzipUncompressedSize(ctx, o)
+			if err != nil {
+				log.Fatalf("uncompressed size: %v", err)
+			}
+			fmt.Printf("uncompressed size: %d bytes (%.2fx compression)\n", n, float64(n)/float64(attrs.Size))
+		}
+	}
+}
+
+// gzipUncompressedSize reads the uncompressed size of a gzip-encoded object
+// from the ISIZE field in the last 4 bytes of its gzip trailer. GCS only
+// records the stored size. ISIZE is the size mod 2^32, so it's only exact for
+// objects under 4GiB uncompressed.
+func gzipUncompressedSize(ctx context.Context, o *storage.ObjectHandle) (int64, error) {
+	r, err := newRangeReader(ctx, o.ReadCompressed(true), -4, -1)
+	if err != nil {
+		return 0, fmt.Errorf("new reader: %w", err)
+	}
+	defer r.Close()
+	var trailer [4]byte
+	if _, err := io.ReadFull(r, trailer[:]); err != nil {
+		return 0, fmt.Errorf("read gzip trailer: %w", err)
+	}
+	return int64(binary.LittleEndian.Uint32(trailer[:])), nil
+}
+
+// recordUpload adds an upload of size bytes to the results, under the
+// strategy it was uploaded with.
+func recordUpload(d time.Duration, size int64) {
+	results.record("upload/"+uploadStrategy(size), d, size)
+}
+
+// failUpload reports a failed upload of size bytes under the strategy it was
+// uploaded with.
+func failUpload(d time.Duration, size int64, err error) {
+	results.fail("upload/"+uploadStrategy(size), d, err)
+}
+
+// stopped reports whether the run was cut short by -max-bytes.
+func stopped(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errBudgetExceeded)
+}
+
+// report prints the end of run totals and writes the summary and config
+// files if requested.
+func report(ctx context.Context) {
+	results.BytesTransferred = budget.transferred()
+	results.StopReason = "completed"
+	if stopped(ctx) {
+		results.StopReason = fmt.Sprintf("%v (limit %d bytes)", errBudgetExceeded, *maxBytes)
+	} else if errors.Is(context.Cause(ctx), errOverallTimeout) {
+		results.StopReason = fmt.Sprintf("%v (%v)", errOverallTimeout, *overallTimeout)
+	}
+	results.ChecksumsDisabled = *noChecksum
+	results.Config = effectiveConfig()
+	results.GC = readGCReport(gcPercent)
 
-	fmt.Printf("time of all ops: %v\n", timetakenC+timetakenD+timetakenU)
+	fmt.Printf("time of all ops: %v\n", results.totalTime())
+	for _, t := range results.totals() {
+		if t.bytes > 0 {
+			fmt.Printf("%s: %d ops, %d bytes in %v (%.2f MiB/s)\n", t.name, t.count, t.bytes, t.duration, mibps(t.bytes, t.duration))
+		}
+	}
+	reportColdStart()
+	reportPlacement()
+	if *transform != "" {
+		reportTransform()
+	}
+	if *noChecksum {
+		fmt.Println("checksums: disabled (upload not integrity-verified)")
+	} else if *sendCRC32CFlag != "" {
+		fmt.Printf("checksums: CRC32C %s sent for server-side validation\n", *sendCRC32CFlag)
+	}
+	if ct, ok := uploadContentType.Load().(string); ok {
+		how := "sniffed"
+		if *noContentTypeSniff {
+			how = "explicit"
+		}
+		fmt.Printf("upload content type: %s (%s)\n", ct, how)
+	}
+	if *separateMetadataClient {
+		fmt.Printf("connections (data client): %v\n", &conns)
+		fmt.Printf("connections (metadata client): %v\n", &metaConns)
+	} else if *connStatsFlag {
+		fmt.Printf("connections: %v\n", &conns)
+	}
+	if (*tlsMinVersionFlag != "" || *tlsCiphersFlag != "") && *api != dp {
+		fmt.Printf("tls: negotiated %v\n", handshakes)
+	}
+	if *wireBytes {
+		results.Wire = wire.report(budget.uploaded(), budget.transferred()-budget.uploaded())
+		fmt.Printf("wire bytes: %v\n", results.Wire)
+	}
+	if *peerIPFlag && *api == dp {
+		fmt.Printf("grpc peers: %v\n", peers)
+	}
+	if *api == dp {
+		fmt.Printf("DirectPath active: %s\n", directPathVerdict())
+	}
+	if reads != nil {
+		fmt.Printf("read cache: %v\n", reads)
+	}
+	if *downloadTo != "" {
+		fmt.Printf("download-to: %v\n", &persisted)
+	}
+	if goroutines != nil {
+		results.PeakGoroutines = goroutines.end()
+		fmt.Printf("goroutines: peak %d (limit %d)\n", results.PeakGoroutines, *maxGoroutines)
+	}
+	fmt.Printf("gc: %v\n", results.GC)
+	fmt.Printf("bytes transferred: %d\n", results.BytesTransferred)
+	fmt.Printf("stopped: %s\n", results.StopReason)
+
+	if *summaryOut != "" {
+		if err := writeJSON(*summaryOut, results); err != nil {
+			log.Fatalf("write summary: %v", err)
+		}
+	}
+	if *configOut != "" {
+		if err := writeJSON(*configOut, results.Config); err != nil {
+			log.Fatalf("write config: %v", err)
+		}
+	}
+	if *timelineOut != "" {
+		if err := writeJSON(*timelineOut, buildTimeline(results.Results)); err != nil {
+			log.Fatalf("write timeline: %v", err)
+		}
+	}
+	if *reportMD != "" {
+		if err := writeMarkdownReport(*reportMD); err != nil {
+			log.Fatalf("write markdown report: %v", err)
+		}
+	}
+	if *resultsObject != "" {
+		// Upload even if -max-bytes or -overall-timeout ended the run.
+		gen, err := uploadResults(context.WithoutCancel(ctx), *resultsObject)
+		if err != nil {
+			log.Fatalf("results object: %v", err)
+		}
+		fmt.Printf("results uploaded to %s (generation %d)\n", *resultsObject, gen)
+	}
+	if metaClient != nil {
+		if err := metaClient.Close(); err != nil {
+			log.Printf("metadata client close: %v", err)
+		}
+	}
+}
+
+// reportColdStart prints the iteration 0 upload and download latency apart
+// from the steady state of all iterations, with its mean, standard deviation
+// and coefficient of variation.
+func reportColdStart() {
+	note := ""
+	if *excludeColdStart {
+		note = ", excluding iteration 0"
+	}
+	results.ColdStartMS = map[string]float64{}
+	results.Jitter = map[string]jitter{}
+	for _, name := range []string{"upload", "download"} {
+		cold, steady := results.split(name, *excludeColdStart)
+		if cold == nil {
+			continue
+		}
+		results.ColdStartMS[name] = cold.DurationMS
+		results.Jitter[name] = latencyJitter(steady)
+		fmt.Printf("%s cold start: %v\n", name, cold.Duration)
+		fmt.Printf("%s steady state: %s %v%s\n", name, latencySummary(steady), results.Jitter[name], note)
+	}
 }
 
 // enableTracing turns on Open Telemetry tracing with export to Cloud Trace.
 func enableTracing(ctx context.Context) func() {
-	exporter, err := texporter.New()
+	export, err := newExportPipeline(*exportConcurrency, func() (sdktrace.SpanExporter, error) {
+		return texporter.New()
+	})
+	if err != nil && *tracingOptional {
+		log.Printf("warning: texporter.New: %v; continuing without tracing (-tracing-optional)", err)
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		if *validateRepro {
+			runErr = errors.New("-validate-reproduction: no spans were recorded; tracing is disabled")
+		}
+		return func() {}
+	}
 	if err != nil {
 		log.Fatalf("texporter.New: %v", err)
 	}
 
+	endpoint, directPath := clientEndpoint()
+
+	// Add your own custom attributes to identify your application
+	custom := append([]attribute.KeyValue{
+		semconv.ServiceNameKey.String("my-resource-with-attr"),
+		attribute.String("gogc", gcPercent),
+		attribute.String("gcs.endpoint", endpoint),
+		attribute.Bool("gcs.directpath", directPath),
+	}, bucketRetention...)
+
 	// Identify your application using resource detection
 	res, err := resource.New(ctx,
 		// Use the GCP resource detector to detect information about the GCP platform
 		resource.WithDetectors(gcp.NewDetector()),
 		// Keep the default detectors
 		resource.WithTelemetrySDK(),
-		// Add your own custom attributes to identify your application
-		resource.WithAttributes(
-			semconv.ServiceNameKey.String("my-resource-with-attr"),
-		),
+		resource.WithAttributes(custom...),
 	)
 	if errors.Is(err, resource.ErrPartialResource) || errors.Is(err, resource.ErrSchemaURLConflict) {
 		log.Println(err)
 	} else if err != nil {
 		log.Fatalf("resource.New: %v", err)
 	}
+	if *dumpResource {
+		printResource(res, custom)
+	}
+
+	var sp sdktrace.SpanProcessor = export
+	var slow *slowSpanFilter
+	if *slowThreshold > 0 {
+		slow = newSlowSpanFilter(sp, *slowThreshold)
+		sp = slow
+	}
 
 	// Create trace provider with the exporter.
 	// By default it uses AlwaysSample() which samples all traces.
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
+	tpOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSpanProcessor(sp),
 		sdktrace.WithResource(res),
-	)
+	}
+	if *samplePerOp != "" {
+		sampler, err := newOpSampler(*samplePerOp)
+		if err != nil {
+			log.Fatalf("-sample-per-op: %v", err)
+		}
+		// Children follow their root's decision so traces stay whole. Roots
+		// under a sampled -traceparent are still sampled by op.
+		tpOpts = append(tpOpts, sdktrace.WithSampler(sdktrace.ParentBased(sampler,
+			sdktrace.WithRemoteParentSampled(sampler))))
+	}
+	var depth *depthTracker
+	if *spanDepth > 0 {
+		depth = &depthTracker{}
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(depth))
+	}
+	var lp *sdklog.LoggerProvider
+	if *otelLogs {
+		lp = newLogProvider(ctx, res)
+	}
+	var mem *tracetest.InMemoryExporter
+	if *recordSpans != "" || *validateRepro {
+		mem = tracetest.NewInMemoryExporter()
+		tpOpts = append(tpOpts, sdktrace.WithSyncer(mem))
+	}
+	tp := sdktrace.NewTracerProvider(tpOpts...)
 
 	otel.SetTracerProvider(tp)
 
@@ -132,19 +956,48 @@ func enableTracing(ctx context.Context) func() {
 		if err := tp.Shutdown(context.Background()); err != nil {
 			log.Fatal(err)
 		}
+		if lp != nil {
+			if err := lp.Shutdown(context.Background()); err != nil {
+				log.Printf("log provider shutdown: %v", err)
+			}
+		}
+		fmt.Printf("trace export: %v\n", export)
+		if *recordSpans != "" {
+			if err := writeSpanRecords(*recordSpans, mem); err != nil {
+				log.Fatalf("record spans: %v", err)
+			}
+		}
+		if *validateRepro {
+			if why := reproductionSkipped(); why != "" {
+				fmt.Printf("reproduction: not validated; %s\n", why)
+			} else {
+				runErr = validateReproduction(mem)
+			}
+		}
+		if depth != nil {
+			fmt.Printf("span depth: %v with -span-depth %d; %d spans not exported\n", depth, *spanDepth, export.dropped())
+		}
+		if slow != nil {
+			fmt.Printf("spans suppressed by -slow-threshold %v: %d; %d dropped with their root still open\n", *slowThreshold, slow.suppressedCount(), slow.unjudgedCount())
+		}
 	}
 }
 
-func upload(ctx context.Context, withSpan bool) (runTime time.Duration, o *storage.ObjectHandle, err error) {
+// tracer returns the tracer used for all app level spans.
+func tracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(*tracerName)
+}
+
+func upload(ctx context.Context, size int64, withSpan bool) (runTime time.Duration, o *storage.ObjectHandle, err error) {
 	var (
 		bucket     = *bucketFlag
-		objectName = fmt.Sprintf("%s_%s", "trace", uuid.New().String())
+		objectName = placedName("trace")
 	)
-	o = client.Bucket(bucket).Object(objectName)
+	o = withWriteKey(client.Bucket(bucket).Object(objectName))
 
 	// Start span.
 	if withSpan {
-		ctxs, span := otel.GetTracerProvider().Tracer("go-ups").Start(ctx, "uploada")
+		ctxs, span := tracer().Start(ctx, "uploada", opKind("upload"))
 		ctx = ctxs
 		span.SetAttributes(
 			attribute.KeyValue{Key: "object", Value: attribute.StringValue(objectName)},
@@ -153,34 +1006,183 @@ func upload(ctx context.Context, withSpan bool) (runTime time.Duration, o *stora
 		defer span.End()
 	}
 
+	ctx, endNested := nestSpans(ctx, *spanDepth)
+	defer endNested()
+
+	defer logOp(ctx, "upload", objectName)(&runTime, &err)
+
 	// Start timer.
 	start := time.Now()
 	defer func() {
 		runTime = time.Since(start)
 	}()
 
+	time.Sleep(time.Second * 1)
+
+	_, err = writeObject(ctx, o, size)
+	return
+}
+
+// uploadContentType holds the content type of the last object uploaded.
+var uploadContentType atomic.Value
+
+// writeObject writes size random bytes to o using the writer settings from
+// the upload flags and returns the new object's attributes. o may carry
+// preconditions.
+func writeObject(ctx context.Context, o *storage.ObjectHandle, size int64) (*storage.ObjectAttrs, error) {
+	objectName := o.ObjectName()
+	var proto *uploadProtocol
+	if *assertGRPCUpload {
+		ctx, proto = trackUploadProtocol(ctx)
+	}
 	w := o.NewWriter(ctx)
+	w.ChunkSize = int(*chunkSize)
+	if *resumableThreshold > 0 {
+		strategy := uploadStrategy(size)
+		if strategy == strategyOneShot {
+			w.ChunkSize = 0
+		}
+		log.Printf("upload %s: %d bytes, %s (threshold %d)", objectName, size, strategy, *resumableThreshold)
+	}
+	if *noChecksum {
+		// Make sure no hash is computed or sent so the server has nothing to
+		// validate the body against.
+		w.SendCRC32C = false
+		w.MD5 = nil
+	}
+	if *sendCRC32CFlag != "" {
+		sum, err := sendCRC32C(size)
+		if err != nil {
+			return nil, err
+		}
+		w.CRC32C = sum
+		w.SendCRC32C = true
+	}
 
-	time.Sleep(time.Second * 1)
+	if *noContentTypeSniff {
+		w.ContentType = "application/octet-stream"
+	}
+	w.PredefinedACL = *predefinedACL
+	w.StorageClass = strings.ToUpper(*storageClass)
+	if *ttlLabel != "" {
+		k, v := ttlLabelKV()
+		w.Metadata = map[string]string{k: v}
+	}
 
-	if _, cErr := io.CopyN(w, rand.Reader, 10*1024*1024); cErr != nil {
+	chunks := newChunkTimer()
+	w.ProgressFunc = chunks.progress
+	var heap *heapWatch
+	if *maxHeap > 0 {
+		heap = watchHeap(100 * time.Millisecond)
+		defer heap.end()
+	}
+	var pipeline *transformPipeline
+	if *transform != "" {
+		p, err := newTransformPipeline(size)
+		if err != nil {
+			return nil, fmt.Errorf("-transform: %w", err)
+		}
+		pipeline = p
+		if _, cErr := io.Copy(w, budget.uploadReader(p)); cErr != nil {
+			p.abort(cErr)
+			w.Close()
+			return nil, fmt.Errorf("io.Copy: %w", cErr)
+		}
+	} else if _, cErr := io.CopyN(w, budget.uploadReader(payload()), size); cErr != nil {
 		w.Close()
-		err = fmt.Errorf("io.CopyN: %w", cErr)
-		return
+		return nil, fmt.Errorf("io.CopyN: %w", cErr)
 	}
 
 	if cErr := w.Close(); cErr != nil {
-		err = fmt.Errorf("w.Close: %w", cErr)
-		return
+		return nil, fmt.Errorf("w.Close: %w", explainACLError(cErr))
+	}
+	// Record the object first so -cleanup removes it even if a check below
+	// fails the upload.
+	recordCreated(objectName, w.Attrs().Generation)
+	if pipeline != nil {
+		pipeline.report(objectName)
+	}
+	if proto != nil {
+		if err := proto.check(objectName); err != nil {
+			return nil, err
+		}
+	}
+	if heap != nil {
+		peak := heap.end()
+		fmt.Printf("upload %s: peak heap in use %d bytes for %d bytes uploaded with chunk size %d\n", objectName, peak, size, w.ChunkSize)
+		if peak > uint64(*maxHeap) {
+			return nil, fmt.Errorf("upload %s: peak heap in use %d bytes exceeds -max-heap %d", objectName, peak, *maxHeap)
+		}
+	}
+	uploadContentType.Store(w.Attrs().ContentType)
+	if *storageClass != "" && !strings.EqualFold(w.Attrs().StorageClass, *storageClass) {
+		return nil, fmt.Errorf("object %s has storage class %s, want %s", objectName, w.Attrs().StorageClass, *storageClass)
+	}
+	if *predefinedACL != "" {
+		reportACL(ctx, o)
+	}
+	if *ttlLabel != "" {
+		k, _ := ttlLabelKV()
+		if v, ok := w.Attrs().Metadata[k]; ok {
+			fmt.Printf("ttl label %s=%s applied to %s\n", k, v, objectName)
+		} else {
+			log.Printf("ttl label %s missing from %s after upload", k, objectName)
+		}
+	}
+	if len(chunks.latencies) > 0 {
+		fmt.Printf("upload chunk latency: %s, %d over -chunk-stall\n", latencySummary(chunks.latencies), chunks.stalls)
 	}
 
-	return
+	return w.Attrs(), nil
 }
 
-func download(ctx context.Context, o *storage.ObjectHandle, withSpan bool) (runTime time.Duration, err error) {
+// ttlLabelKV splits -ttl-label into its key and value. A bare key is set
+// to "true".
+func ttlLabelKV() (key, value string) {
+	k, v, ok := strings.Cut(*ttlLabel, "=")
+	if !ok {
+		v = "true"
+	}
+	return k, v
+}
+
+const (
+	strategyOneShot   = "one-shot"
+	strategyResumable = "resumable"
+)
+
+// uploadStrategy returns how an object of the given size is uploaded:
+// one-shot below -resumable-threshold, resumable otherwise. With no
+// threshold the client picks based on -chunk-size.
+func uploadStrategy(size int64) string {
+	if *resumableThreshold > 0 {
+		if size < int64(*resumableThreshold) {
+			return strategyOneShot
+		}
+		return strategyResumable
+	}
+	if *chunkSize == 0 || size <= int64(*chunkSize) {
+		return strategyOneShot
+	}
+	return strategyResumable
+}
+
+// download reads the first length bytes of o in two parts split across two
+// user spans, reproducing how GCSFuse serves a kernel read from a longer
+// range reader.
+//
+// The reader is normally opened with the context carrying user-span-1, so
+// the library's spans for the whole read nest under that span even though
+// it ends half way through. With -separate-io-context the reader gets its
+// own context with no span in it instead, like the kernel request context
+// in GCSFuse, so the I/O outlives and is traced apart from the user spans.
+// Cancellation still flows from ctx either way.
+func download(ctx context.Context, o *storage.ObjectHandle, length int64, withSpan bool) (runTime time.Duration, err error) {
+	ioCtx := trace.ContextWithSpanContext(ctx, trace.SpanContext{})
+
 	// Start span.
 	if withSpan {
-		ctxs, span := otel.GetTracerProvider().Tracer("go-ups").Start(ctx, "downloads")
+		ctxs, span := tracer().Start(ctx, "downloads", opKind("download"))
 		ctx = ctxs
 		span.SetAttributes(
 			attribute.KeyValue{Key: "object", Value: attribute.StringValue(o.ObjectName())},
@@ -189,6 +1191,11 @@ func download(ctx context.Context, o *storage.ObjectHandle, withSpan bool) (runT
 		defer span.End()
 	}
 
+	ctx, endNested := nestSpans(ctx, *spanDepth)
+	defer endNested()
+
+	defer logOp(ctx, "download", o.ObjectName())(&runTime, &err)
+
 	// Start timer.
 	start := time.Now()
 	defer func() {
@@ -196,7 +1203,7 @@ func download(ctx context.Context, o *storage.ObjectHandle, withSpan bool) (runT
 	}()
 
 	// 1 - user code (GCSFuse) starts a trace on ctx
-	ctxa, span := otel.GetTracerProvider().Tracer("go-downs").Start(ctx, "user-span-1")
+	ctxa, span := tracer().Start(ctx, "user-span-1", opKind("download"))
 	ctx = ctxa
 	span.SetAttributes(
 		attribute.KeyValue{Key: "object", Value: attribute.StringValue(o.ObjectName())},
@@ -204,16 +1211,41 @@ func download(ctx context.Context, o *storage.ObjectHandle, withSpan bool) (runT
 	)
 
 	// 2 - r := NewRangeReader(ctx, {some range larger than what the kernel call was}
-	r, cErr := o.NewRangeReader(ctx, 0, 1024*1024)
+	readerCtx := ctx
+	if *separateIOContext {
+		readerCtx = ioCtx
+	}
+	r, cErr := newRangeReader(readerCtx, o, 0, length)
 	if cErr != nil {
 		err = fmt.Errorf("new reader: %w", cErr)
 		return
 	}
 
+	var (
+		dst = io.Discard
+		src = budget.reader(r)
+	)
+	if *downloadTo != "" {
+		// download is the only read of o, so the file holds just its bytes.
+		pf, cErr := createDownloadFile(o.ObjectName(), 0, true)
+		if cErr != nil {
+			r.Close()
+			err = fmt.Errorf("-download-to: %w", cErr)
+			return
+		}
+		defer func() {
+			if cErr := pf.close(); err == nil && cErr != nil {
+				err = fmt.Errorf("-download-to: %w", cErr)
+			}
+		}()
+		dst, src = pf, pf.from(src)
+	}
+
 	// time.Sleep(time.Second * 1) // Try a small sleep here
 
 	//3 - io.CopyN(r, {bytes 0 - 1024}) // or something similar that copies the first N bytes from the reader
-	if _, cErr := io.CopyN(io.Discard, r, 1024); cErr != nil {
+	first := min(length, 1024)
+	if _, cErr := io.CopyN(dst, src, first); cErr != nil {
 		r.Close()
 		err = fmt.Errorf("io.Copy: %w", cErr)
 		return
@@ -225,14 +1257,14 @@ func download(ctx context.Context, o *storage.ObjectHandle, withSpan bool) (runT
 	time.Sleep(time.Second * 100)
 
 	//5. - user code starts a new trace on ctx
-	_, spanB := otel.GetTracerProvider().Tracer("go-ups").Start(ctx, "user-span-2")
+	_, spanB := tracer().Start(ctx, "user-span-2")
 	span.SetAttributes(
 		attribute.KeyValue{Key: "object", Value: attribute.StringValue(o.ObjectName())},
 		attribute.KeyValue{Key: "mykey", Value: attribute.StringValue(*api)},
 	)
 
 	//5 - io.CopyN(r, ..) // next N bytes copied from r
-	if _, cErr := io.CopyN(io.Discard, r, 1024*1024-1024); cErr != nil {
+	if _, cErr := io.CopyN(dst, src, length-first); cErr != nil {
 		r.Close()
 		err = fmt.Errorf("io.Copy: %w", cErr)
 		return
@@ -261,28 +1293,36 @@ func download(ctx context.Context, o *storage.ObjectHandle, withSpan bool) (runT
 	return
 }
 
-func listObjs(ctx context.Context, withSpan bool) (runTime time.Duration, err error) {
+func listObjs(ctx context.Context, withSpan bool) (runTime time.Duration, count int, err error) {
 	var (
 		bucket = *bucketFlag
 	)
 
 	// Start span.
 	if withSpan {
-		ctxs, span := otel.GetTracerProvider().Tracer("go-another").Start(ctx, "listobjsa")
+		ctxs, span := tracer().Start(ctx, "listobjsa", opKind("list"))
 		ctx = ctxs
 		span.SetAttributes(
 			attribute.KeyValue{Key: "mykey", Value: attribute.StringValue(*api)},
+			attribute.KeyValue{Key: "start_offset", Value: attribute.StringValue(*startOffset)},
+			attribute.KeyValue{Key: "end_offset", Value: attribute.StringValue(*endOffset)},
 		)
 		defer span.End()
 	}
 
+	ctx, endNested := nestSpans(ctx, *spanDepth)
+	defer endNested()
+
+	defer logOp(ctx, "list", "")(&runTime, &err)
+
 	// Start timer.
 	start := time.Now()
 	defer func() {
 		runTime = time.Since(start)
 	}()
 
-	it := client.Bucket(bucket).Objects(ctx, nil)
+	q := &storage.Query{StartOffset: *startOffset, EndOffset: *endOffset}
+	it := metadataClient().Bucket(bucket).Objects(ctx, q)
 	for {
 		_, cErr := it.Next()
 		if cErr == iterator.Done {
@@ -292,46 +1332,79 @@ func listObjs(ctx context.Context, withSpan bool) (runTime time.Duration, err er
 			err = fmt.Errorf("Bucket(%q).Objects: %w", bucket, cErr)
 			return
 		}
+		count++
 	}
 	return
 }
 
-func getClient(ctx context.Context) *storage.Client {
-	switch *api {
+// getClient creates the client for -api. extra options, such as a custom
+// endpoint, are applied after the transport's own.
+func getClient(ctx context.Context, extra ...option.ClientOption) *storage.Client {
+	return newClient(ctx, *api, extra...)
+}
+
+// newClient creates a client for the given api.
+func newClient(ctx context.Context, api string, extra ...option.ClientOption) *storage.Client {
+	return newClientConns(ctx, api, &conns, extra...)
+}
+
+// newClientConns is newClient counting the client's connection reuse into
+// stats.
+func newClientConns(ctx context.Context, api string, stats *connStats, extra ...option.ClientOption) *storage.Client {
+	var opts []option.ClientOption
+	if *downscopePrefix != "" {
+		ts, err := downscopedTokenSource(ctx)
+		if err != nil {
+			log.Fatalf("downscope: %v", err)
+		}
+		opts = append(opts, option.WithTokenSource(ts))
+	}
+
+	switch api {
 	case dp:
-		if err := os.Setenv("GOOGLE_CLOUD_ENABLE_DIRECT_PATH_XDS", "true"); err != nil {
-			log.Fatalf("set DP env var: %v", err)
+		checkDirectPathEnv()
+		opts = append(opts, grpcOptions(api, stats)...)
+		if *disableClientMetrics {
+			opts = append(opts, storage.WithDisabledClientMetrics())
+			log.Println("gRPC client metrics disabled")
 		}
-		client, err := storage.NewGRPCClient(ctx)
+		client, err := storage.NewGRPCClient(ctx, append(opts, extra...)...)
 		if err != nil {
 			log.Fatalf("NewGRPCClient: %v", err)
 		}
 		return client
-	case http2:
-		client, err := storage.NewClient(ctx)
-		if err != nil {
-			log.Fatalf("NewClient: %v", err)
+	case http1, http2:
+		if !customTransport(api) {
+			// Measure storage.NewClient's own transport when nothing needs
+			// to change it.
+			client, err := storage.NewClient(ctx, append(opts, extra...)...)
+			if err != nil {
+				log.Fatalf("NewClient: %v", err)
+			}
+			return client
 		}
-		return client
-	case http1:
-		// Use a base transport which disables HTTP/2.
-		base := &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 100,
-			// This disables HTTP/2 in transport.
-			TLSNextProto: make(
-				map[string]func(string, *tls.Conn) http.RoundTripper,
-			),
-		}
-
-		trans, err := htransport.NewTransport(ctx, base, option.WithScopes(raw.DevstorageFullControlScope))
+		var base http.RoundTripper = baseTransport(api)
+		if *connStatsFlag || *separateMetadataClient {
+			base = &connTrackingTransport{next: base, stats: stats}
+		}
+		if *tlsMinVersionFlag != "" || *tlsCiphersFlag != "" {
+			base = &tlsTrackingTransport{next: base, stats: handshakes}
+		}
+		if faults != nil {
+			base = &faultTransport{next: base, f: faults}
+		}
+		if uploadRPCs != nil {
+			base = &uploadTimingTransport{next: base, u: uploadRPCs}
+		}
+		opts = append(opts, option.WithScopes(raw.DevstorageFullControlScope))
+		trans, err := htransport.NewTransport(ctx, base, opts...)
 		if err != nil {
-			log.Fatalf("creating transport: %v", base)
+			log.Fatalf("creating transport: %v", err)
 		}
 		c := http.Client{Transport: trans}
 
 		// Supply this client to storage.NewClient
-		client, err = storage.NewClient(ctx, option.WithHTTPClient(&c))
+		client, err := storage.NewClient(ctx, append([]option.ClientOption{option.WithHTTPClient(&c)}, extra...)...)
 		if err != nil {
 			log.Fatalf("NewClient: %v", err)
 		}
@@ -341,3 +1414,117 @@ func getClient(ctx context.Context) *storage.Client {
 		return nil
 	}
 }
+
+// customTransport reports whether the api's client needs the transport from
+// baseTransport: http1 always does, to turn off HTTP/2, and http2 only when
+// a flag tunes or wraps the transport.
+func customTransport(api string) bool {
+	return api == http1 ||
+		*readBuffer > 0 || *writeBuffer > 0 || *connPool > 0 ||
+		*connectTimeout > 0 || *wireBytes || *caCert != "" || proxyURL != nil ||
+		tlsMinVersion != 0 || tlsCipherSuites != nil || h2WindowsSet() ||
+		*connStatsFlag || *separateMetadataClient || faults != nil || uploadRPCs != nil
+}
+
+// baseTransport returns the transport the http1 and http2 clients are built
+// on, tuned by -conn-pool and the buffer size flags.
+func baseTransport(api string) *http.Transport {
+	base := &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 100,
+		ReadBufferSize:      int(*readBuffer),
+		WriteBufferSize:     int(*writeBuffer),
+		ForceAttemptHTTP2:   true,
+		Proxy:               httpProxy,
+	}
+	if *connectTimeout > 0 {
+		base.DialContext = dialContext
+	}
+	if *wireBytes {
+		base.DialContext = countingDialer(&wire, base.DialContext)
+	}
+	if *caCert != "" {
+		pool, err := loadCACert(*caCert)
+		if err != nil {
+			log.Fatalf("-ca-cert: %v", err)
+		}
+		base.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	base.TLSClientConfig = pinTLS(base.TLSClientConfig)
+	if *connPool > 0 {
+		base.MaxIdleConns = *connPool
+		base.MaxIdleConnsPerHost = *connPool
+	}
+	if api == http2 && h2WindowsSet() {
+		base.HTTP2 = h2Config()
+	}
+	if api == http1 {
+		// This disables HTTP/2 in transport.
+		base.ForceAttemptHTTP2 = false
+		base.TLSNextProto = make(
+			map[string]func(string, *tls.Conn) http.RoundTripper,
+		)
+	}
+	return base
+}
+
+// loadCACert returns a cert pool holding the PEM encoded certificates in
+// path.
+func loadCACert(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// grpcOptions returns the client options for the gRPC client's connection
+// pool and buffer sizes.
+func grpcOptions(api string, stats *connStats) []option.ClientOption {
+	var opts []option.ClientOption
+	if *connPool > 0 {
+		opts = append(opts, option.WithGRPCConnectionPool(*connPool))
+	}
+	if *readBuffer > 0 {
+		opts = append(opts, option.WithGRPCDialOption(grpc.WithReadBufferSize(int(*readBuffer))))
+	}
+	if *writeBuffer > 0 {
+		opts = append(opts, option.WithGRPCDialOption(grpc.WithWriteBufferSize(int(*writeBuffer))))
+	}
+	if *connectTimeout > 0 {
+		opts = append(opts, option.WithGRPCDialOption(grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.DefaultConfig,
+			MinConnectTimeout: *connectTimeout,
+		})))
+	}
+	if o := grpcProxyDialer(); o != nil {
+		opts = append(opts, option.WithGRPCDialOption(o))
+	}
+	if faults != nil {
+		for _, o := range faults.dialOptions() {
+			opts = append(opts, option.WithGRPCDialOption(o))
+		}
+	}
+	if uploadRPCs != nil {
+		opts = append(opts, option.WithGRPCDialOption(grpc.WithStatsHandler(&uploadTimingHandler{u: uploadRPCs})))
+	}
+	if *peerIPFlag || api == dp {
+		// grpc-dp clients, side clients included, always record peers for
+		// the DirectPath verdict.
+		opts = append(opts, option.WithGRPCDialOption(grpc.WithStatsHandler(&peerIPHandler{stats: peers, spanAttr: *peerIPFlag})))
+	}
+	if *connStatsFlag || *separateMetadataClient {
+		opts = append(opts, option.WithGRPCDialOption(grpc.WithStatsHandler(&connStatsHandler{stats: stats})))
+	}
+	if *wireBytes {
+		opts = append(opts, option.WithGRPCDialOption(grpc.WithStatsHandler(&wireBytesHandler{w: &wire})))
+	}
+	if *assertGRPCUpload {
+		opts = append(opts, option.WithGRPCDialOption(grpc.WithStatsHandler(grpcMethodHandler{})))
+	}
+	return opts
+}
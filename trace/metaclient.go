@@ -0,0 +1,19 @@
+package main
+
+import "cloud.google.com/go/storage"
+
+// metaClient, under -separate-metadata-client, is a second client for
+// Attrs, list and stat calls so they don't share a connection pool with data
+// transfers. metaConns counts its connection reuse.
+var (
+	metaClient *storage.Client
+	metaConns  connStats
+)
+
+// metadataClient returns the client for metadata calls.
+func metadataClient() *storage.Client {
+	if metaClient != nil {
+		return metaClient
+	}
+	return client
+}
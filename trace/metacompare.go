@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+)
+
+// metaCompare runs -meta-calls Attrs and Update calls against the same
+// object through a JSON client and a gRPC client created side by side, and
+// prints their latencies as a table. Calls alternate between the clients so
+// any drift over the run affects both alike.
+// Without -object a fresh object is uploaded first.
+func metaCompare(ctx context.Context) error {
+	jsonAPI := http2
+	if *api == http1 {
+		jsonAPI = http1
+	}
+	clients := []struct {
+		name string
+		c    *storage.Client
+	}{
+		{jsonAPI, newClient(ctx, jsonAPI)},
+		{dp, newClient(ctx, dp)},
+	}
+	defer func() {
+		for _, c := range clients {
+			c.c.Close()
+		}
+	}()
+
+	name := *objectFlag
+	if name == "" {
+		name = fmt.Sprintf("%s%s_%s", *downscopePrefix, "metacompare", uuid.New().String())
+		o := client.Bucket(*bucketFlag).Object(name).If(storage.Conditions{DoesNotExist: true})
+		if _, err := writeObject(ctx, o, int64(*objectSize)); err != nil {
+			return fmt.Errorf("upload: %w", err)
+		}
+	}
+
+	attrs := make([][]time.Duration, len(clients))
+	updates := make([][]time.Duration, len(clients))
+	for i := range *metaCalls {
+		for j, c := range clients {
+			o := c.c.Bucket(*bucketFlag).Object(name)
+			start := time.Now()
+			if _, err := o.Attrs(ctx); err != nil {
+				results.fail(c.name+" attrs", time.Since(start), err)
+				return fmt.Errorf("%s Attrs: %w", c.name, err)
+			}
+			attrs[j] = append(attrs[j], time.Since(start))
+			results.record(c.name+" attrs", attrs[j][len(attrs[j])-1], 0)
+
+			start = time.Now()
+			md := map[string]string{"meta-compare": strconv.Itoa(i)}
+			if _, err := o.Update(ctx, storage.ObjectAttrsToUpdate{Metadata: md}); err != nil {
+				results.fail(c.name+" update", time.Since(start), err)
+				return fmt.Errorf("%s Update: %w", c.name, err)
+			}
+			updates[j] = append(updates[j], time.Since(start))
+			results.record(c.name+" update", updates[j][len(updates[j])-1], 0)
+		}
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "op\tclient\tn\tp50\tp90\tp99\tmax")
+	for _, row := range []struct {
+		op string
+		ds [][]time.Duration
+	}{{"attrs", attrs}, {"update", updates}} {
+		for j, c := range clients {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", row.op, c.name, latencyColumns(row.ds[j]))
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// updateMetadata sets -metadata on -object -iterations times, as FUSE does on
+// chmod or setxattr, reporting the latency of each update and the
+// metageneration it produced. With -if-metageneration-match the first update
+// is conditional on that metageneration and each later one on the previous
+// result.
+func updateMetadata(ctx context.Context) error {
+	if *objectFlag == "" {
+		return fmt.Errorf("-op update-metadata requires -object")
+	}
+	md, err := parseKV(*metadataFlag)
+	if err != nil {
+		return fmt.Errorf("-metadata: %w", err)
+	}
+	if len(md) == 0 {
+		return fmt.Errorf("-op update-metadata requires -metadata")
+	}
+
+	metagen := *ifMetagenMatch
+	var latencies []time.Duration
+	for i := range max(*iterations, 1) {
+		results.setIteration(i)
+		o := client.Bucket(*bucketFlag).Object(*objectFlag)
+		if metagen > 0 {
+			o = o.If(storage.Conditions{MetagenerationMatch: metagen})
+		}
+		start := time.Now()
+		attrs, err := o.Update(ctx, storage.ObjectAttrsToUpdate{Metadata: md})
+		d := time.Since(start)
+		if err != nil {
+			results.fail("update-metadata", d, err)
+			if isPreconditionFailed(err) {
+				return fmt.Errorf("update %d: metageneration is no longer %d: %w", i, metagen, err)
+			}
+			return fmt.Errorf("update %d: %w", i, err)
+		}
+		latencies = append(latencies, d)
+		results.record("update-metadata", d, 0)
+		fmt.Printf("update %d: %v, metageneration now %d\n", i, d, attrs.Metageneration)
+		if metagen > 0 {
+			metagen = attrs.Metageneration
+		}
+	}
+	fmt.Printf("update-metadata latency: %s\n", latencySummary(latencies))
+	return nil
+}
+
+// parseKV parses a comma separated list of key=value pairs.
+func parseKV(s string) (map[string]string, error) {
+	m := map[string]string{}
+	if s == "" {
+		return m, nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid key=value pair %q", kv)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// noopOp measures the tool's own fixed cost with no object I/O: building and
+// closing a client, starting and ending -iterations spans around nothing,
+// and flushing them to the exporter. Subtract it from real runs to leave the
+// cost of the GCS calls alone.
+func noopOp(ctx context.Context) error {
+	start := time.Now()
+	c := getClient(ctx)
+	construct := time.Since(start)
+
+	start = time.Now()
+	for i := range *iterations {
+		results.setIteration(i)
+		t := time.Now()
+		_, span := tracer().Start(ctx, "noop")
+		span.End()
+		results.record("noop", time.Since(t), 0)
+	}
+	spans := time.Since(start)
+
+	flush := time.Duration(0)
+	if tp, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider); ok {
+		start = time.Now()
+		if err := tp.ForceFlush(ctx); err != nil {
+			return fmt.Errorf("flush spans: %w", err)
+		}
+		flush = time.Since(start)
+	}
+
+	start = time.Now()
+	if err := c.Close(); err != nil {
+		return fmt.Errorf("close client: %w", err)
+	}
+	teardown := time.Since(start)
+
+	n := max(*iterations, 1)
+	fmt.Printf("noop: client construction %v\n", construct)
+	fmt.Printf("noop: %d spans created and ended in %v (%v per span)\n", *iterations, spans, spans/time.Duration(n))
+	fmt.Printf("noop: span flush %v\n", flush)
+	fmt.Printf("noop: client teardown %v\n", teardown)
+	fmt.Printf("noop: total overhead %v\n", construct+spans+flush+teardown)
+	return nil
+}
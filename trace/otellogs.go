@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// opLogger emits -otel-logs records; nil when they're off.
+var opLogger otellog.Logger
+
+// newLogProvider creates the OTLP log pipeline for -otel-logs, sending to the
+// collector named by the standard OTEL_EXPORTER_OTLP_* environment
+// variables, and sets opLogger.
+func newLogProvider(ctx context.Context, res *resource.Resource) *sdklog.LoggerProvider {
+	exp, err := otlploggrpc.New(ctx)
+	if err != nil {
+		log.Fatalf("otlploggrpc.New: %v", err)
+	}
+	lp := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exp)),
+		sdklog.WithResource(res),
+	)
+	opLogger = lp.Logger(*tracerName)
+	return lp
+}
+
+// logOp emits a record that op on object started and returns a func that
+// emits its end, with the duration and any error, for the caller to defer:
+//
+//	defer logOp(ctx, "upload", name)(&runTime, &err)
+//
+// Records carry the trace and span ID of the span in ctx.
+func logOp(ctx context.Context, op, object string) func(d *time.Duration, err *error) {
+	if opLogger == nil {
+		return func(*time.Duration, *error) {}
+	}
+	emit := func(event string, sev otellog.Severity, attrs ...otellog.KeyValue) {
+		var r otellog.Record
+		r.SetTimestamp(time.Now())
+		r.SetSeverity(sev)
+		r.SetBody(otellog.StringValue(op + " " + event))
+		r.AddAttributes(otellog.String("op", op), otellog.String("event", event), otellog.String("object", object), otellog.String("api", *api))
+		r.AddAttributes(attrs...)
+		opLogger.Emit(ctx, r)
+	}
+	emit("start", otellog.SeverityInfo)
+	return func(d *time.Duration, err *error) {
+		attrs := []otellog.KeyValue{otellog.Float64("duration_ms", float64(*d)/float64(time.Millisecond))}
+		if *err != nil {
+			emit("end", otellog.SeverityError, append(attrs, otellog.String("error", (*err).Error()))...)
+			return
+		}
+		emit("end", otellog.SeverityInfo, attrs...)
+	}
+}
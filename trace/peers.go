@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/stats"
+)
+
+// peers counts gRPC connections and RPCs per remote peer IP for -peer-ip.
+var peers = &peerStats{conns: map[string]int{}, rpcs: map[string]int{}}
+
+type peerStats struct {
+	mu    sync.Mutex
+	conns map[string]int
+	rpcs  map[string]int
+}
+
+// String lists each peer with its connection and RPC counts, busiest
+// first.
+func (p *peerStats) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var ips []string
+	for ip := range p.rpcs {
+		ips = append(ips, ip)
+	}
+	for ip := range p.conns {
+		if _, ok := p.rpcs[ip]; !ok {
+			ips = append(ips, ip)
+		}
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		if p.rpcs[ips[i]] != p.rpcs[ips[j]] {
+			return p.rpcs[ips[i]] > p.rpcs[ips[j]]
+		}
+		return ips[i] < ips[j]
+	})
+	parts := make([]string, len(ips))
+	for i, ip := range ips {
+		parts[i] = fmt.Sprintf("%s (%d conns, %d rpcs)", ip, p.conns[ip], p.rpcs[ip])
+	}
+	return fmt.Sprintf("%d peers: %s", len(ips), strings.Join(parts, ", "))
+}
+
+// peerIPHandler is a gRPC stats.Handler recording the peer each connection
+// and RPC went to and, with spanAttr, setting it as the net.peer.ip
+// attribute of the span the RPC runs under.
+type peerIPHandler struct {
+	stats    *peerStats
+	spanAttr bool
+}
+
+func peerIP(addr net.Addr) string {
+	if addr == nil {
+		return "unknown"
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+func (h *peerIPHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *peerIPHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	hdr, ok := s.(*stats.OutHeader)
+	if !ok {
+		return
+	}
+	ip := peerIP(hdr.RemoteAddr)
+	if h.spanAttr {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("net.peer.ip", ip))
+	}
+	h.stats.mu.Lock()
+	h.stats.rpcs[ip]++
+	h.stats.mu.Unlock()
+}
+
+func (h *peerIPHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	ip := peerIP(info.RemoteAddr)
+	h.stats.mu.Lock()
+	h.stats.conns[ip]++
+	h.stats.mu.Unlock()
+	return ctx
+}
+
+func (h *peerIPHandler) HandleConn(context.Context, stats.ConnStats) {}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// persisted totals the time downloads spent on the network versus writing
+// and syncing -download-to files.
+var persisted persistStats
+
+type persistStats struct {
+	mu                   sync.Mutex
+	files                int
+	bytes                int64
+	network, write, sync time.Duration
+}
+
+func (s *persistStats) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := fmt.Sprintf("%d files, %d bytes: network %v, disk write %v", s.files, s.bytes, s.network, s.write)
+	if *fsyncDownloads {
+		out += fmt.Sprintf(", fsync %v", s.sync)
+	}
+	return out
+}
+
+// persistFile is a -download-to file being written.
+type persistFile struct {
+	f                    *os.File
+	n                    int64
+	network, write, sync time.Duration
+}
+
+// createDownloadFile opens the file object's bytes starting at offset are
+// written to. If -download-to is a directory the file is named after the
+// object beneath it; otherwise every download goes to -download-to itself.
+// With truncate set, for a read that writes all of the file from offset 0,
+// the file is truncated so no stale tail of an older, longer file is left;
+// otherwise it isn't, so range reads of one object fill in their part of it.
+func createDownloadFile(object string, offset int64, truncate bool) (*persistFile, error) {
+	path := *downloadTo
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		path = filepath.Join(path, filepath.FromSlash(object))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	flags := os.O_WRONLY | os.O_CREATE
+	if truncate && offset == 0 {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(max(offset, 0), io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &persistFile{f: f}, nil
+}
+
+func (p *persistFile) Write(b []byte) (int, error) {
+	start := time.Now()
+	n, err := p.f.Write(b)
+	p.write += time.Since(start)
+	p.n += int64(n)
+	return n, err
+}
+
+// from times reads from r as network time.
+func (p *persistFile) from(r io.Reader) io.Reader {
+	return &timedReader{r: r, d: &p.network}
+}
+
+// close syncs the file if -fsync is set, closes it and adds its timings to
+// the run's totals.
+func (p *persistFile) close() error {
+	if *fsyncDownloads {
+		start := time.Now()
+		if err := p.f.Sync(); err != nil {
+			p.f.Close()
+			return fmt.Errorf("fsync %s: %w", p.f.Name(), err)
+		}
+		p.sync = time.Since(start)
+	}
+	if err := p.f.Close(); err != nil {
+		return err
+	}
+	persisted.mu.Lock()
+	defer persisted.mu.Unlock()
+	persisted.files++
+	persisted.bytes += p.n
+	persisted.network += p.network
+	persisted.write += p.write
+	persisted.sync += p.sync
+	return nil
+}
+
+type timedReader struct {
+	r io.Reader
+	d *time.Duration
+}
+
+func (t *timedReader) Read(b []byte) (int, error) {
+	start := time.Now()
+	n, err := t.r.Read(b)
+	*t.d += time.Since(start)
+	return n, err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	placementSequential = "sequential"
+	placementRandom     = "random"
+)
+
+// placementBase and placementNext number sequential names from the run's
+// start time, so they increase like timestamp-named objects do.
+var (
+	placementBase = time.Now().UnixNano()
+	placementNext atomic.Int64
+)
+
+// validatePlacement checks -placement.
+func validatePlacement() error {
+	switch *placement {
+	case "", placementSequential, placementRandom:
+		return nil
+	}
+	return fmt.Errorf("invalid -placement %q: want %s or %s", *placement, placementSequential, placementRandom)
+}
+
+// placedName returns a name for a new object of kind under -downscope-prefix.
+// With -placement=sequential names increase monotonically, the worst case for
+// hotspotting the index; otherwise they end in a random uuid.
+func placedName(kind string) string {
+	if *placement == placementSequential {
+		return fmt.Sprintf("%s%s_%d", *downscopePrefix, kind, placementBase+placementNext.Add(1))
+	}
+	return fmt.Sprintf("%s%s_%s", *downscopePrefix, kind, uuid.New().String())
+}
+
+// reportPlacement prints the upload and seed write latency under -placement.
+func reportPlacement() {
+	if *placement == "" {
+		return
+	}
+	for _, name := range []string{"upload", "seed"} {
+		if _, ds := results.split(name, false); len(ds) > 0 {
+			fmt.Printf("placement %s: %s write latency %s\n", *placement, name, latencySummary(ds))
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	_ "net/http/pprof"
+)
+
+// servePprof serves net/http/pprof on addr until ctx is done, so profiles can
+// be pulled on demand during long runs with
+// `go tool pprof http://addr/debug/pprof/profile`.
+func servePprof(ctx context.Context, addr string) {
+	srv := &http.Server{Addr: addr}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	go func() {
+		log.Printf("serving pprof on http://%s/debug/pprof/", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("pprof server: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// prefetchRead reads an object sequentially in -window-size windows twice:
+// once fetching each window only when it's needed, and once keeping
+// -prefetch-windows windows in flight ahead of the reader, as read-ahead
+// would. It reports the throughput of each and the time the reader spent
+// waiting for data. Without -object, an -object-size object is uploaded
+// first.
+func prefetchRead(ctx context.Context) error {
+	o, size, err := prefetchTarget(ctx)
+	if err != nil {
+		return err
+	}
+	if *windowSize <= 0 {
+		return fmt.Errorf("-window-size must be positive")
+	}
+
+	type pass struct {
+		name  string
+		ahead int
+		wall  time.Duration
+		stall time.Duration
+	}
+	passes := []*pass{{name: "on demand"}, {name: fmt.Sprintf("prefetch %d", *prefetchWindows), ahead: *prefetchWindows}}
+	for _, p := range passes {
+		start := time.Now()
+		stall, err := readWindows(ctx, o, size, int64(*windowSize), p.ahead)
+		if err != nil {
+			results.fail("prefetch-read/"+p.name, time.Since(start), err)
+			return fmt.Errorf("%s: %w", p.name, err)
+		}
+		p.wall, p.stall = time.Since(start), stall
+		results.record("prefetch-read/"+p.name, p.wall, size)
+		fmt.Printf("%s: %d bytes in %v (%.2f MiB/s), waited %v for data\n", p.name, size, p.wall, mibps(size, p.wall), p.stall)
+	}
+
+	if hidden := passes[0].stall - passes[1].stall; hidden > 0 {
+		fmt.Printf("prefetch hid %v of read latency (%.2fx throughput)\n", hidden, float64(passes[0].wall)/float64(passes[1].wall))
+	} else {
+		fmt.Println("prefetch hid no read latency")
+	}
+	return nil
+}
+
+// prefetchTarget returns -object and its size, or a freshly uploaded
+// object.
+func prefetchTarget(ctx context.Context) (*storage.ObjectHandle, int64, error) {
+	if *objectFlag != "" {
+		o := client.Bucket(*bucketFlag).Object(*objectFlag)
+		attrs, err := metadataClient().Bucket(*bucketFlag).Object(*objectFlag).Attrs(ctx)
+		if err != nil {
+			return nil, 0, fmt.Errorf("Attrs: %w", err)
+		}
+		return o, attrs.Size, nil
+	}
+	size := int64(*objectSize)
+	timetaken, o, err := upload(ctx, size, *addSpans)
+	if err != nil {
+		failUpload(timetaken, size, err)
+		return nil, 0, fmt.Errorf("upload: %w", err)
+	}
+	recordUpload(timetaken, size)
+	return o, size, nil
+}
+
+type fetchedWindow struct {
+	data []byte
+	err  error
+}
+
+// readWindows reads o's size bytes window bytes at a time, with up to ahead
+// further windows being fetched while the current one is consumed, and
+// returns the total time spent waiting on a window that hadn't arrived. The
+// reader consumes each window as soon as it arrives, so the wait is all read
+// latency that prefetch failed to hide.
+func readWindows(ctx context.Context, o *storage.ObjectHandle, size, window int64, ahead int) (time.Duration, error) {
+	n := int((size + window - 1) / window)
+	ready := make([]chan fetchedWindow, n)
+	fetch := func(i int) {
+		ready[i] = make(chan fetchedWindow, 1)
+		go func() {
+			off := int64(i) * window
+			ready[i] <- fetchWindow(ctx, o, off, min(window, size-off))
+		}()
+	}
+
+	var stall time.Duration
+	for i := range min(ahead+1, n) {
+		fetch(i)
+	}
+	for i := range n {
+		start := time.Now()
+		w := <-ready[i]
+		stall += time.Since(start)
+		if next := i + ahead + 1; next < n {
+			fetch(next)
+		}
+		if w.err != nil {
+			// Windows still in flight finish into their buffered channels.
+			return stall, fmt.Errorf("window %d: %w", i, w.err)
+		}
+	}
+	return stall, nil
+}
+
+func fetchWindow(ctx context.Context, o *storage.ObjectHandle, off, length int64) fetchedWindow {
+	r, err := newRangeReader(ctx, o, off, length)
+	if err != nil {
+		return fetchedWindow{err: err}
+	}
+	defer r.Close()
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(budget.reader(r), buf); err != nil {
+		return fetchedWindow{err: err}
+	}
+	return fetchedWindow{data: buf}
+}
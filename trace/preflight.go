@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// checkLocation fails if -bucket isn't in the -require-location region, so
+// latency numbers are never taken against a bucket on the other side of the
+// world by mistake.
+func checkLocation(ctx context.Context) error {
+	attrs, err := metadataClient().Bucket(*bucketFlag).Attrs(ctx)
+	if err != nil {
+		return fmt.Errorf("Bucket(%q).Attrs: %w", *bucketFlag, err)
+	}
+	if !strings.EqualFold(attrs.Location, *requireLocation) {
+		return fmt.Errorf("bucket %q is in %s, expected %s", *bucketFlag, attrs.Location, strings.ToUpper(*requireLocation))
+	}
+	return nil
+}
+
+// bucketRetention describes how -bucket retains deleted and overwritten
+// objects, as resource attributes for every span.
+var bucketRetention []attribute.KeyValue
+
+// logRetention logs -bucket's soft delete policy, retention policy and
+// object retention mode, which decide whether deletes such as -cleanup's can
+// succeed and how long deleted data lingers, and sets bucketRetention. A
+// failure to read them, e.g. under -downscope-prefix, is logged and ignored.
+func logRetention(ctx context.Context) {
+	attrs, err := metadataClient().Bucket(*bucketFlag).Attrs(ctx)
+	if err != nil {
+		log.Printf("bucket retention: Bucket(%q).Attrs: %v", *bucketFlag, err)
+		return
+	}
+
+	softDelete := time.Duration(0)
+	if p := attrs.SoftDeletePolicy; p != nil {
+		softDelete = p.RetentionDuration
+	}
+	retention, locked := time.Duration(0), false
+	if p := attrs.RetentionPolicy; p != nil {
+		retention, locked = p.RetentionPeriod, p.IsLocked
+	}
+	mode := attrs.ObjectRetentionMode
+	if mode == "" {
+		mode = "Disabled"
+	}
+
+	fmt.Printf("bucket %s: soft delete %v, retention period %v (locked %t), object retention %s\n",
+		*bucketFlag, softDelete, retention, locked, mode)
+	bucketRetention = []attribute.KeyValue{
+		attribute.String("gcs.bucket.soft_delete", softDelete.String()),
+		attribute.String("gcs.bucket.retention_period", retention.String()),
+		attribute.Bool("gcs.bucket.retention_locked", locked),
+		attribute.String("gcs.bucket.object_retention", mode),
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// probe reads the first byte of -object every -probe-interval for -duration,
+// reporting the fraction of reads that succeeded. It returns an error if
+// that drops below -min-availability.
+func probe(ctx context.Context, withSpan bool) error {
+	if *objectFlag == "" {
+		return fmt.Errorf("-op probe requires -object")
+	}
+	o := client.Bucket(*bucketFlag).Object(*objectFlag)
+
+	var (
+		ok, failed int
+		failures   []time.Duration
+	)
+	ticker := time.NewTicker(*probeInterval)
+	defer ticker.Stop()
+	deadline := time.After(*duration)
+loop:
+	for {
+		d, err := rangeRead(ctx, o, 0, 1, withSpan)
+		if err != nil {
+			failed++
+			failures = append(failures, d)
+			fmt.Printf("probe failed after %v: %v\n", d, err)
+			results.fail("probe", d, err)
+		} else {
+			ok++
+			results.record("probe", d, 1)
+		}
+
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-deadline:
+			break loop
+		case <-ticker.C:
+		}
+	}
+
+	total := ok + failed
+	availability := 100 * float64(ok) / float64(total)
+	fmt.Printf("probe: %d/%d succeeded, availability %.3f%%\n", ok, total, availability)
+	if failed > 0 {
+		fmt.Printf("probe failure latency: %s\n", latencySummary(failures))
+	}
+	if availability < *minAvailability {
+		return fmt.Errorf("availability %.3f%% is below -min-availability %.3f%%", availability, *minAvailability)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// tuningProfile holds the flag defaults a -profile applies for each family
+// of transports.
+type tuningProfile struct {
+	http map[string]string
+	grpc map[string]string
+}
+
+var profiles = map[string]tuningProfile{
+	// Small chunks so small writes go out in one request, and plenty of
+	// warm connections so requests don't wait on a handshake.
+	"low-latency": {
+		http: map[string]string{"chunk-size": "1MiB", "conn-pool": "200", "read-buffer": "32KiB", "write-buffer": "32KiB"},
+		grpc: map[string]string{"chunk-size": "1MiB", "conn-pool": "4", "read-buffer": "32KiB", "write-buffer": "32KiB"},
+	},
+	"balanced": {
+		http: map[string]string{"chunk-size": "16MiB", "conn-pool": "100", "read-buffer": "64KiB", "write-buffer": "64KiB"},
+		grpc: map[string]string{"chunk-size": "16MiB", "conn-pool": "2", "read-buffer": "64KiB", "write-buffer": "64KiB"},
+	},
+	// Large chunks and buffers to keep big transfers streaming.
+	"high-throughput": {
+		http: map[string]string{"chunk-size": "64MiB", "conn-pool": "256", "read-buffer": "1MiB", "write-buffer": "1MiB"},
+		grpc: map[string]string{"chunk-size": "64MiB", "conn-pool": "8", "read-buffer": "1MiB", "write-buffer": "1MiB"},
+	},
+}
+
+// applyProfile sets the flags of the selected -profile for the selected
+// -api, leaving any flag given explicitly on the command line alone, and
+// prints the resolved settings.
+func applyProfile() {
+	if *profile == "" {
+		return
+	}
+	p, ok := profiles[*profile]
+	if !ok {
+		log.Fatalf("invalid -profile %q", *profile)
+	}
+	settings := p.http
+	if *api == dp {
+		settings = p.grpc
+	}
+
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		note := ""
+		if explicit[name] {
+			note = " (overridden)"
+		} else if err := flag.Set(name, settings[name]); err != nil {
+			log.Fatalf("profile %s: -%s: %v", *profile, name, err)
+		}
+		fmt.Printf("profile %s: -%s=%s%s\n", *profile, name, flag.Lookup(name).Value, note)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+	"google.golang.org/grpc"
+)
+
+// proxyURL is the parsed -proxy, or nil to honour HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY.
+var proxyURL *url.URL
+
+// parseProxy validates -proxy, which must be an http, https or socks5 URL
+// with a host.
+func parseProxy(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q: want http, https or socks5", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", s)
+	}
+	return u, nil
+}
+
+// httpProxy is the base transport's Proxy func. net/http handles both the
+// http and the socks5 schemes itself.
+func httpProxy(r *http.Request) (*url.URL, error) {
+	if proxyURL == nil {
+		return http.ProxyFromEnvironment(r)
+	}
+	return proxyURL, nil
+}
+
+// grpcProxyDialer returns a dial option that connects through -proxy, or nil
+// to leave gRPC to honour the proxy environment variables itself.
+func grpcProxyDialer() grpc.DialOption {
+	if proxyURL == nil {
+		return nil
+	}
+	return grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		if proxyURL.Scheme == "socks5" || proxyURL.Scheme == "socks5h" {
+			d, err := proxy.FromURL(proxyURL, netDialer{})
+			if err != nil {
+				return nil, err
+			}
+			return d.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+		}
+		return dialConnect(ctx, addr)
+	})
+}
+
+// netDialer dials the proxy itself, with -connect-timeout when set.
+type netDialer struct{}
+
+func (netDialer) Dial(network, addr string) (net.Conn, error) {
+	return netDialer{}.DialContext(context.Background(), network, addr)
+}
+
+func (netDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if *connectTimeout > 0 {
+		return dialContext(ctx, network, addr)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, addr)
+}
+
+// proxyAddr returns the host:port of the http(s) -proxy, defaulting the
+// port to the scheme's as net/http does.
+func proxyAddr() string {
+	if proxyURL.Port() != "" {
+		return proxyURL.Host
+	}
+	port := "80"
+	if proxyURL.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// dialConnect opens a tunnel to addr with an HTTP CONNECT through the
+// http(s) -proxy. An https proxy is spoken to over TLS.
+func dialConnect(ctx context.Context, addr string) (net.Conn, error) {
+	c, err := netDialer{}.DialContext(ctx, "tcp", proxyAddr())
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme == "https" {
+		tc := tls.Client(c, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("proxy TLS handshake: %w", err)
+		}
+		c = tc
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if u := proxyURL.User; u != nil {
+		p, _ := u.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+p)))
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+		defer c.SetDeadline(time.Time{})
+	}
+	if err := req.Write(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(c)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		c.Close()
+		return nil, fmt.Errorf("proxy CONNECT %s: %s", addr, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		c.Close()
+		return nil, fmt.Errorf("proxy CONNECT %s: unexpected data after response", addr)
+	}
+	return c, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceURL is where the run's spans can be found in the Cloud Trace console,
+// set when tracing is enabled.
+var traceURL string
+
+// traceConsoleURL returns the Cloud Trace console URL for the run: the trace
+// -traceparent names, or else the project's trace list. It's empty if the
+// project can't be determined.
+func traceConsoleURL(ctx context.Context) string {
+	project := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if project == "" && metadata.OnGCE() {
+		project, _ = metadata.ProjectIDWithContext(ctx)
+	}
+	if project == "" {
+		return ""
+	}
+	url := "https://console.cloud.google.com/traces/list?project=" + project
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		url += "&tid=" + sc.TraceID().String()
+	}
+	return url
+}
+
+// writeMarkdownReport writes the run as markdown to path: the configuration,
+// a latency table and a throughput table per op, and the trace link.
+func writeMarkdownReport(path string) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# %s run\n\n", results.Op)
+
+	cfg := results.Config
+	b.WriteString("## Configuration\n\n| Setting | Value |\n| --- | --- |\n")
+	fmt.Fprintf(&b, "| version | `%s` |\n| bucket | `%s` |\n| api | `%s` |\n| object size | %d |\n",
+		cfg.Version, cfg.Bucket, cfg.API, cfg.ObjectSize)
+	names := make([]string, 0, len(cfg.Flags))
+	for name := range cfg.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "| -%s | `%s` |\n", name, cfg.Flags[name])
+	}
+
+	totals := results.totals()
+	b.WriteString("\n## Latency\n\n| Op | n | p50 | p90 | p99 | max |\n| --- | --- | --- | --- | --- | --- |\n")
+	for _, t := range totals {
+		var ds []time.Duration
+		for _, r := range results.Results {
+			if r.Name == t.name {
+				ds = append(ds, r.Duration)
+			}
+		}
+		fmt.Fprintf(&b, "| %s | %s |\n", t.name, strings.ReplaceAll(latencyColumns(ds), "\t", " | "))
+	}
+
+	b.WriteString("\n## Throughput\n\n| Op | Ops | Bytes | Time | MiB/s |\n| --- | --- | --- | --- | --- |\n")
+	for _, t := range totals {
+		if t.bytes > 0 {
+			fmt.Fprintf(&b, "| %s | %d | %d | %v | %.2f |\n", t.name, t.count, t.bytes, t.duration, mibps(t.bytes, t.duration))
+		}
+	}
+	fmt.Fprintf(&b, "\nBytes transferred: %d. Stopped: %s.\n", results.BytesTransferred, results.StopReason)
+
+	if traceURL != "" {
+		fmt.Fprintf(&b, "\n## Trace\n\n%s\n", traceURL)
+	}
+	return os.WriteFile(path, b.Bytes(), 0o644)
+}
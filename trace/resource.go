@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// printResource prints every attribute of res, marking those that came from
+// custom rather than from the detectors.
+func printResource(res *resource.Resource, custom []attribute.KeyValue) {
+	ours := map[attribute.Key]bool{}
+	for _, kv := range custom {
+		ours[kv.Key] = true
+	}
+	fmt.Printf("resource: %d attributes, schema %q\n", res.Len(), res.SchemaURL())
+	for _, kv := range res.Attributes() {
+		source := "detected"
+		if ours[kv.Key] {
+			source = "custom"
+		}
+		fmt.Printf("resource: %s=%s (%s)\n", kv.Key, kv.Value.Emit(), source)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+)
+
+// restoreBench uploads -count objects, soft-deletes them and then restores
+// them with -concurrency workers, reporting restore throughput and latency.
+// Each restore names the generation that was deleted, so it can't pick up
+// another soft-deleted generation of the same name. -bucket must have a soft
+// delete policy.
+func restoreBench(ctx context.Context) error {
+	attrs, err := metadataClient().Bucket(*bucketFlag).Attrs(ctx)
+	if err != nil {
+		return fmt.Errorf("Bucket(%q).Attrs: %w", *bucketFlag, err)
+	}
+	if p := attrs.SoftDeletePolicy; p == nil || p.RetentionDuration == 0 {
+		return fmt.Errorf("bucket %s has no soft delete policy, so deleted objects can't be restored", *bucketFlag)
+	}
+
+	var (
+		size   = int64(*objectSize)
+		run    = uuid.New().String()
+		mu     sync.Mutex
+		gens   = map[string]int64{}
+		jobs   []string
+		nameOf = func(job string) string {
+			return fmt.Sprintf("%srestore_%s_%s", *downscopePrefix, run, job)
+		}
+	)
+	for i := range *count {
+		jobs = append(jobs, strconv.Itoa(i))
+	}
+
+	err = forEach(jobs, *concurrency, func(_ int, job string) error {
+		o := client.Bucket(*bucketFlag).Object(nameOf(job))
+		attrs, err := writeObject(ctx, o.If(storage.Conditions{DoesNotExist: true}), size)
+		if err != nil {
+			return fmt.Errorf("upload %s: %w", o.ObjectName(), err)
+		}
+		if err := o.Generation(attrs.Generation).Delete(ctx); err != nil {
+			return fmt.Errorf("delete %s#%d: %w", o.ObjectName(), attrs.Generation, err)
+		}
+		mu.Lock()
+		gens[job] = attrs.Generation
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restore-bench: soft-deleted %d objects\n", len(gens))
+
+	var latencies []time.Duration
+	start := time.Now()
+	err = forEach(jobs, *concurrency, func(worker int, job string) error {
+		name, gen := nameOf(job), gens[job]
+		o := client.Bucket(*bucketFlag).Object(name).Generation(gen)
+		t := time.Now()
+		restored, err := o.Restore(ctx, &storage.RestoreOptions{})
+		d := time.Since(t)
+		if err != nil {
+			results.failOn(worker, "restore", d, err)
+			return fmt.Errorf("restore %s#%d: %w", name, gen, err)
+		}
+		recordCreated(name, restored.Generation)
+		if restored.Size != size {
+			return fmt.Errorf("restore %s#%d: restored %d bytes, want %d", name, gen, restored.Size, size)
+		}
+		results.recordOn(worker, "restore", d, size)
+		mu.Lock()
+		latencies = append(latencies, d)
+		mu.Unlock()
+		return nil
+	})
+	wall := time.Since(start)
+
+	fmt.Printf("restore-bench: restored %d of %d objects in %v (%.1f objects/s, %.2f MiB/s)\n",
+		len(latencies), *count, wall, float64(len(latencies))/wall.Seconds(), mibps(int64(len(latencies))*size, wall))
+	fmt.Printf("restore-bench: restore latency %s\n", latencySummary(latencies))
+	return err
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// parseGCSURL splits a gs://bucket/object URL.
+func parseGCSURL(u string) (bucket, object string, err error) {
+	rest, ok := strings.CutPrefix(u, "gs://")
+	bucket, object, _ = strings.Cut(rest, "/")
+	if !ok || bucket == "" || object == "" {
+		return "", "", fmt.Errorf("invalid %q: want gs://BUCKET/OBJECT", u)
+	}
+	return bucket, object, nil
+}
+
+// uploadResults writes the run summary as JSON to the -results-object URL
+// with the run's own client and returns the generation written.
+func uploadResults(ctx context.Context, url string) (int64, error) {
+	bucket, object, err := parseGCSURL(url)
+	if err != nil {
+		return 0, err
+	}
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	w := client.Bucket(bucket).Object(object).NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		w.Close()
+		return 0, fmt.Errorf("write %s: %w", url, err)
+	}
+	if err := w.Close(); err != nil {
+		return 0, fmt.Errorf("write %s: %w", url, err)
+	}
+	return w.Attrs().Generation, nil
+}
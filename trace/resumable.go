@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/stats"
+)
+
+// uploadRPCs is set for -op resumable-overhead and small-writes, before the
+// client is created, so that getClient instruments its transport with it.
+var uploadRPCs *uploadTimer
+
+// uploadTimer times the requests of an upload by what they do: start a
+// resumable session or carry data. On gRPC it also counts the messages sent
+// on write streams. Timings are grouped under the current
+// phase so resumable and one-shot uploads can be told apart.
+type uploadTimer struct {
+	mu    sync.Mutex
+	phase string
+	times map[string][]time.Duration
+}
+
+func (u *uploadTimer) setPhase(p string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.phase = p
+}
+
+func (u *uploadTimer) observe(kind string, d time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	key := u.phase + " " + kind
+	u.times[key] = append(u.times[key], d)
+}
+
+func (u *uploadTimer) get(key string) []time.Duration {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.times[key]
+}
+
+// uploadTimingTransport classifies JSON API upload requests: the POST that
+// opens a resumable session, and the POSTs and PUTs carrying data.
+type uploadTimingTransport struct {
+	next http.RoundTripper
+	u    *uploadTimer
+}
+
+func (t *uploadTimingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasPrefix(req.URL.Path, "/upload/") {
+		return t.next.RoundTrip(req)
+	}
+	kind := "data"
+	if req.Method == http.MethodPost && req.URL.Query().Get("uploadType") == "resumable" {
+		kind = "session-start"
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	t.u.observe(kind, time.Since(start))
+	return resp, err
+}
+
+type rpcMethodKey struct{}
+
+// uploadTimingHandler is a gRPC stats.Handler classifying StartResumableWrite
+// as session start and the write streams as data.
+type uploadTimingHandler struct {
+	u *uploadTimer
+}
+
+func (h *uploadTimingHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, rpcMethodKey{}, info.FullMethodName)
+}
+
+func (h *uploadTimingHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	method, _ := ctx.Value(rpcMethodKey{}).(string)
+	if _, ok := s.(*stats.OutPayload); ok && strings.HasSuffix(method, "WriteObject") {
+		// Counted, not timed: one per message sent on a write stream.
+		h.u.observe("message", 0)
+		return
+	}
+	end, ok := s.(*stats.End)
+	if !ok {
+		return
+	}
+	switch {
+	case strings.HasSuffix(method, "/StartResumableWrite"):
+		h.u.observe("session-start", end.EndTime.Sub(end.BeginTime))
+	case strings.HasSuffix(method, "/BidiWriteObject"), strings.HasSuffix(method, "/WriteObject"):
+		h.u.observe("data", end.EndTime.Sub(end.BeginTime))
+	}
+}
+
+func (h *uploadTimingHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *uploadTimingHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+// resumableOverhead uploads -iterations small objects resumably and the
+// same number in one shot, and reports how much of a resumable upload's
+// latency goes to the session-start round trip. An upload that fits in one
+// chunk always goes out in one shot, so the resumable uploads are one byte
+// larger than the minimum 256KiB chunk; the one-shot uploads match that
+// size.
+func resumableOverhead(ctx context.Context) error {
+	const chunk = googleapi.MinUploadChunkSize
+	size := int64(chunk + 1)
+
+	totals := map[string][]time.Duration{}
+	for i := range max(*iterations, 1) {
+		results.setIteration(i)
+		for _, phase := range []string{"resumable", "one-shot"} {
+			uploadRPCs.setPhase(phase)
+			name := fmt.Sprintf("%s%s_%s", *downscopePrefix, "resumable", uuid.New().String())
+			w := client.Bucket(*bucketFlag).Object(name).If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+			if phase == "resumable" {
+				w.ChunkSize = chunk
+			} else {
+				w.ChunkSize = 0
+			}
+			start := time.Now()
+			if _, err := io.CopyN(w, budget.uploadReader(payload()), size); err != nil {
+				w.Close()
+				results.fail("upload/"+phase, time.Since(start), err)
+				return fmt.Errorf("%s upload: %w", phase, err)
+			}
+			if err := w.Close(); err != nil {
+				results.fail("upload/"+phase, time.Since(start), err)
+				return fmt.Errorf("%s upload: %w", phase, err)
+			}
+			d := time.Since(start)
+			recordCreated(name, w.Attrs().Generation)
+			totals[phase] = append(totals[phase], d)
+			results.record("upload/"+phase, d, size)
+		}
+	}
+
+	fmt.Printf("resumable-overhead on %s, %d byte objects:\n", *api, size)
+	fmt.Printf("  resumable total: %s\n", latencySummary(totals["resumable"]))
+	fmt.Printf("    session start: %s\n", latencySummary(uploadRPCs.get("resumable session-start")))
+	fmt.Printf("    data:          %s\n", latencySummary(uploadRPCs.get("resumable data")))
+	fmt.Printf("  one-shot total:  %s\n", latencySummary(totals["one-shot"]))
+	if s := slices.Clone(uploadRPCs.get("resumable session-start")); len(s) > 0 {
+		slices.Sort(s)
+		fmt.Printf("one-shot would save about %v (p50 session start) per small write\n", percentile(s, 50))
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// faults is set for -op retry-check, before the client is created, so that
+// getClient wraps its transport with it.
+var faults *faultInjector
+
+// faultInjector fails the first upload request it sees with a 503 (or
+// Unavailable on gRPC) and passes every other request through, counting the
+// upload attempts.
+type faultInjector struct {
+	injected atomic.Bool
+	attempts atomic.Int32
+}
+
+// fail reports whether the upload request about to be sent should be
+// failed, counting it as an attempt either way.
+func (f *faultInjector) fail() bool {
+	f.attempts.Add(1)
+	return f.injected.CompareAndSwap(false, true)
+}
+
+// faultTransport injects f's fault on the JSON API.
+type faultTransport struct {
+	next http.RoundTripper
+	f    *faultInjector
+}
+
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasPrefix(req.URL.Path, "/upload/") || !t.f.fail() {
+		return t.next.RoundTrip(req)
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return &http.Response{
+		Status:     "503 Service Unavailable",
+		StatusCode: http.StatusServiceUnavailable,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {"text/plain"}},
+		Body:       io.NopCloser(strings.NewReader("injected by retry-check")),
+		Request:    req,
+	}, nil
+}
+
+// isUploadRPC reports whether method starts or carries an upload.
+func isUploadRPC(method string) bool {
+	return strings.HasSuffix(method, "/WriteObject") ||
+		strings.HasSuffix(method, "/BidiWriteObject") ||
+		strings.HasSuffix(method, "/StartResumableWrite")
+}
+
+// dialOptions injects f's fault on gRPC.
+func (f *faultInjector) dialOptions() []grpc.DialOption {
+	errInjected := status.Error(codes.Unavailable, "injected by retry-check")
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			if isUploadRPC(method) && f.fail() {
+				return errInjected
+			}
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			if isUploadRPC(method) && f.fail() {
+				return nil, errInjected
+			}
+			return streamer(ctx, desc, cc, method, opts...)
+		}),
+	}
+}
+
+// retryCheck uploads an object through the fault injector and fails unless
+// the injected 503 was retried and the upload then succeeded. The upload is
+// conditional on the object not existing, since the client only retries
+// uploads it knows are idempotent.
+func retryCheck(ctx context.Context) error {
+	name := fmt.Sprintf("%s%s_%s", *downscopePrefix, "retrycheck", uuid.New().String())
+	o := client.Bucket(*bucketFlag).Object(name).If(storage.Conditions{DoesNotExist: true})
+	_, err := writeObject(ctx, o, int64(*objectSize))
+	attempts := faults.attempts.Load()
+	fmt.Printf("retry-check on %s: fault injected %t, %d upload attempts\n", *api, faults.injected.Load(), attempts)
+	if !faults.injected.Load() {
+		return errors.New("no upload request reached the fault injector")
+	}
+	if err != nil {
+		return fmt.Errorf("upload didn't recover from the injected failure: %w", err)
+	}
+	if attempts < 2 {
+		return fmt.Errorf("client didn't retry: %d upload attempts", attempts)
+	}
+	fmt.Printf("upload of %s recovered after the injected failure\n", name)
+	return nil
+}
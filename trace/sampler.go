@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// opKindKey is the span attribute, set when an app level span starts, that
+// -sample-per-op keys its sampling decision on.
+const opKindKey = attribute.Key("op.kind")
+
+// opKind returns the start option marking a span as an operation of kind k.
+func opKind(k string) trace.SpanStartOption {
+	return trace.WithAttributes(opKindKey.String(k))
+}
+
+// opSampler samples root spans with the ratio configured for their op.kind
+// attribute. Spans of kinds without a ratio, including library spans started
+// outside any app level span, are always sampled. Each root draws its own
+// random number rather than hashing the trace ID, which every root shares
+// under -traceparent.
+type opSampler struct {
+	ratios map[string]float64
+	desc   string
+}
+
+// newOpSampler parses a -sample-per-op list such as
+// "upload=1.0,download=0.1,list=0.5".
+func newOpSampler(s string) (*opSampler, error) {
+	kv, err := parseKV(s)
+	if err != nil {
+		return nil, err
+	}
+	o := &opSampler{ratios: map[string]float64{}}
+	var keys []string
+	for k, v := range kv {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 || r > 1 {
+			return nil, fmt.Errorf("invalid ratio %q for %s: want 0 to 1", v, k)
+		}
+		o.ratios[k] = r
+		keys = append(keys, k+"="+v)
+	}
+	sort.Strings(keys)
+	o.desc = fmt.Sprintf("OpSampler{%s}", strings.Join(keys, ","))
+	return o, nil
+}
+
+func (o *opSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	for _, a := range p.Attributes {
+		if a.Key != opKindKey {
+			continue
+		}
+		if r, ok := o.ratios[a.Value.AsString()]; ok {
+			res := sdktrace.SamplingResult{
+				Decision:   sdktrace.Drop,
+				Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+			}
+			if rand.Float64() < r {
+				res.Decision = sdktrace.RecordAndSample
+			}
+			return res
+		}
+	}
+	return sdktrace.AlwaysSample().ShouldSample(p)
+}
+
+func (o *opSampler) Description() string {
+	return o.desc
+}
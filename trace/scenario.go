@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// scenario is a named GCSFuse workload: the flags that reproduce it.
+type scenario struct {
+	desc  string
+	flags map[string]string
+}
+
+var scenarios = map[string]scenario{
+	"sequential-read": {
+		desc:  "read whole objects under -prefix one after another, as cat or cp does",
+		flags: map[string]string{"op": opFanRead, "fanout": "16", "concurrency": "1"},
+	},
+	"random-read": {
+		desc:  "small reads at scattered offsets of one large object, as a database file sees",
+		flags: map[string]string{"op": opSeekRead, "object-size": "256MiB", "seeks": "64", "seek-read-size": "128KiB"},
+	},
+	"write-heavy": {
+		desc:  "many concurrent medium uploads under -prefix, as an untar or checkpoint does",
+		flags: map[string]string{"op": opSeed, "seed-count": "200", "object-size": "16MiB", "concurrency": "16", "chunk-size": "8MiB"},
+	},
+	"ls-l": {
+		desc:  "list -prefix and stat every entry, as ls -l does",
+		flags: map[string]string{"op": opListStat},
+	},
+	"open-close-churn": {
+		desc:  "rewrite one small object over and over, as open-write-close on the same file does",
+		flags: map[string]string{"op": opChurn, "churn-count": "50", "object-size": "64KiB", "churn-precondition": "true"},
+	},
+}
+
+// applyScenario sets the flags of the selected -scenario, leaving any flag
+// given explicitly on the command line alone, and prints the scenario's
+// definition.
+func applyScenario() {
+	if *scenarioFlag == "" {
+		return
+	}
+	sc, ok := scenarios[*scenarioFlag]
+	if !ok {
+		names := make([]string, 0, len(scenarios))
+		for name := range scenarios {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		log.Fatalf("invalid -scenario %q; want one of %s", *scenarioFlag, strings.Join(names, ", "))
+	}
+
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	fmt.Printf("scenario %s: %s\n", *scenarioFlag, sc.desc)
+	names := make([]string, 0, len(sc.flags))
+	for name := range sc.flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		note := ""
+		if explicit[name] {
+			note = " (overridden)"
+		} else if err := flag.Set(name, sc.flags[name]); err != nil {
+			log.Fatalf("scenario %s: -%s: %v", *scenarioFlag, name, err)
+		}
+		fmt.Printf("scenario %s: -%s=%s%s\n", *scenarioFlag, name, flag.Lookup(name).Value, note)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// script is the parsed -scenario-script.
+var script []scriptStep
+
+// scriptStep is one line of a scenario script.
+type scriptStep struct {
+	line int
+	verb string
+	args []string
+	size int64
+}
+
+// scriptArgs is how many arguments each script verb takes.
+var scriptArgs = map[string]int{
+	"mkdir":  1, // mkdir DIR: create the DIR/ placeholder object
+	"upload": 2, // upload NAME SIZE
+	"list":   1, // list PREFIX
+	"read":   1, // read NAME
+	"rename": 2, // rename SRC DST: copy, then delete SRC
+	"delete": 1, // delete NAME
+}
+
+// loadScript reads a scenario script of "VERB ARGS..." lines, such as
+// "upload dir/a 1MiB", with blank lines and lines starting with # ignored.
+// Names are relative to a directory made for the run. The script is checked
+// as a whole before anything runs: every verb and size must parse, and
+// every read, rename or delete must name an object an earlier step created.
+func loadScript(path string) ([]scriptStep, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		steps  []scriptStep
+		exists = map[string]bool{}
+	)
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		s := scriptStep{line: line, verb: fields[0], args: fields[1:]}
+		n, ok := scriptArgs[s.verb]
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: unknown step %q: want mkdir, upload, list, read, rename or delete", path, line, s.verb)
+		}
+		if len(s.args) != n {
+			return nil, fmt.Errorf("%s:%d: %s takes %d arguments, got %d", path, line, s.verb, n, len(s.args))
+		}
+		switch s.verb {
+		case "mkdir":
+			exists[strings.TrimSuffix(s.args[0], "/")+"/"] = true
+		case "upload":
+			if s.size, err = parseSize(s.args[1]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			exists[s.args[0]] = true
+		case "read", "rename", "delete":
+			if !exists[s.args[0]] {
+				return nil, fmt.Errorf("%s:%d: %s %s: no earlier step creates it", path, line, s.verb, s.args[0])
+			}
+			if s.verb == "rename" {
+				exists[s.args[1]] = true
+			}
+			if s.verb != "read" {
+				delete(exists, s.args[0])
+			}
+		}
+		steps = append(steps, s)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("%s: no steps", path)
+	}
+	return steps, nil
+}
+
+// runScript runs script under a directory of its own as a single trace: a
+// scenario-script root span with a child span per step, under which the
+// client's spans for that step nest. It prints each step's latency.
+func runScript(ctx context.Context) error {
+	dir := fmt.Sprintf("%sscript_%s/", *downscopePrefix, uuid.New().String())
+	ctx, root := tracer().Start(ctx, "scenario-script")
+	defer root.End()
+	root.SetAttributes(attribute.String("dir", dir), attribute.Int("steps", len(script)))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "line\tstep\tlatency\tresult")
+	for i, s := range script {
+		results.setIteration(i)
+		summary := s.verb + " " + strings.Join(s.args, " ")
+		sctx, span := tracer().Start(ctx, "step "+summary)
+		start := time.Now()
+		note, bytes, err := runStep(sctx, dir, s)
+		d := time.Since(start)
+		span.End()
+		if err != nil {
+			results.fail("script/"+s.verb, d, err)
+			tw.Flush()
+			return fmt.Errorf("line %d: %s: %w", s.line, summary, err)
+		}
+		results.record("script/"+s.verb, d, bytes)
+		fmt.Fprintf(tw, "%d\t%s\t%v\t%s\n", s.line, summary, d, note)
+	}
+	return tw.Flush()
+}
+
+// runStep runs one step under dir and returns a note on its result and the
+// bytes it moved.
+func runStep(ctx context.Context, dir string, s scriptStep) (string, int64, error) {
+	b := client.Bucket(*bucketFlag)
+	switch s.verb {
+	case "mkdir":
+		name := dir + strings.TrimSuffix(s.args[0], "/") + "/"
+		if _, err := writeObject(ctx, b.Object(name), 0); err != nil {
+			return "", 0, err
+		}
+		return "created", 0, nil
+	case "upload":
+		if _, err := writeObject(ctx, b.Object(dir+s.args[0]), s.size); err != nil {
+			return "", 0, err
+		}
+		return fmt.Sprintf("%d bytes", s.size), s.size, nil
+	case "list":
+		names, err := listNames(ctx, dir+s.args[0], 0)
+		if err != nil {
+			return "", 0, err
+		}
+		return fmt.Sprintf("%d objects", len(names)), 0, nil
+	case "read":
+		o := b.Object(dir + s.args[0])
+		before := budget.transferred()
+		if err := readAll(ctx, o); err != nil {
+			return "", 0, err
+		}
+		n := budget.transferred() - before
+		return fmt.Sprintf("%d bytes", n), n, nil
+	case "rename":
+		src, dst := b.Object(dir+s.args[0]), b.Object(dir+s.args[1])
+		attrs, err := dst.CopierFrom(src).Run(ctx)
+		if err != nil {
+			return "", 0, fmt.Errorf("copy: %w", err)
+		}
+		recordCreated(attrs.Name, attrs.Generation)
+		if err := src.Delete(ctx); err != nil {
+			return "", 0, fmt.Errorf("delete source: %w", err)
+		}
+		return "renamed", 0, nil
+	case "delete":
+		if err := b.Object(dir + s.args[0]).Delete(ctx); err != nil {
+			return "", 0, err
+		}
+		return "deleted", 0, nil
+	}
+	return "", 0, fmt.Errorf("unknown step %q", s.verb)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+)
+
+// seedObjects uploads -seed-count objects of -object-size under -prefix with
+// -concurrency workers, naming them from a counter (PREFIXobj-000000,
+// PREFIXobj-000001, ...) so later runs can address them. Each upload is
+// conditional on the name being free. On a collision the object is retried
+// under the next unused counter value, up to -seed-retries times, or skipped
+// with -no-clobber. With -placement=random the names end in a uuid instead.
+func seedObjects(ctx context.Context) error {
+	var (
+		size       = int64(*objectSize)
+		next       atomic.Int64
+		collisions atomic.Int64
+		skipped    atomic.Int64
+		jobs       []string
+	)
+	next.Store(int64(*seedCount))
+	for i := range *seedCount {
+		jobs = append(jobs, strconv.Itoa(i))
+	}
+
+	nameFor := func(i int) string {
+		if *placement == placementRandom {
+			return fmt.Sprintf("%sobj-%s", *prefix, uuid.New().String())
+		}
+		return fmt.Sprintf("%sobj-%06d", *prefix, i)
+	}
+
+	start := time.Now()
+	err := forEach(jobs, *concurrency, func(worker int, job string) error {
+		i, _ := strconv.Atoi(job)
+		name := nameFor(i)
+		for attempt := 0; ; attempt++ {
+			o := client.Bucket(*bucketFlag).Object(name).If(storage.Conditions{DoesNotExist: true})
+			t := time.Now()
+			_, err := writeObject(ctx, o, size)
+			switch {
+			case err == nil:
+				results.recordOn(worker, "seed", time.Since(t), size)
+				return nil
+			case !isAlreadyExists(err):
+				results.failOn(worker, "seed", time.Since(t), err)
+				return fmt.Errorf("seed %s: %w", name, err)
+			}
+			collisions.Add(1)
+			if *noClobber {
+				skipped.Add(1)
+				log.Printf("seed %s: name taken, skipping", name)
+				return nil
+			}
+			if attempt >= *seedRetries {
+				return fmt.Errorf("seed %s: name taken after %d retries", name, attempt)
+			}
+			taken := name
+			name = nameFor(int(next.Add(1) - 1))
+			log.Printf("seed %s: name taken, retrying as %s", taken, name)
+		}
+	})
+
+	wall := time.Since(start)
+	fmt.Printf("seeded %d of %d objects under %q in %v: %d names collided, %d skipped\n",
+		int64(*seedCount)-skipped.Load(), *seedCount, *prefix, wall, collisions.Load(), skipped.Load())
+	return err
+}
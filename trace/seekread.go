@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// seekReadOp uploads a new object and runs seekRead against it.
+func seekReadOp(ctx context.Context) {
+	size := int64(*objectSize)
+	timetaken, o, err := upload(ctx, size, *addSpans)
+	if stopped(ctx) {
+		return
+	}
+	if err != nil {
+		failUpload(timetaken, size, err)
+		log.Fatalf("upload failed: %v\n", err)
+	}
+	recordUpload(timetaken, size)
+
+	if err := seekRead(ctx, o, size, *addSpans); err != nil && !stopped(ctx) {
+		log.Fatalf("seek-read failed: %v\n", err)
+	}
+}
+
+// seekRead emulates non-sequential access: after reading a chunk from the
+// start of o, it jumps to -seeks scattered offsets, opening a fresh range
+// reader at each since storage.Reader can't seek.
+func seekRead(ctx context.Context, o *storage.ObjectHandle, size int64, withSpan bool) error {
+	readSize := int64(*seekReadSize)
+	if readSize > size {
+		readSize = size
+	}
+
+	offsets := []int64{0}
+	for range *seeks {
+		offsets = append(offsets, rand.Int64N(size-readSize+1))
+	}
+
+	var latencies []time.Duration
+	for i, off := range offsets {
+		d, err := rangeRead(ctx, o, off, readSize, withSpan)
+		if err != nil {
+			results.fail("seek-read", d, err)
+			return fmt.Errorf("read at %d: %w", off, err)
+		}
+		latencies = append(latencies, d)
+		results.record("seek-read", d, readSize)
+		fmt.Printf("seek-read %d: offset %d, %d bytes in %v\n", i, off, readSize, d)
+	}
+	// The first read is sequential; only the jumps count as seeks.
+	fmt.Printf("seek-read latency: %s\n", latencySummary(latencies[1:]))
+	return nil
+}
+
+// rangeRead reads length bytes of o starting at offset.
+func rangeRead(ctx context.Context, o *storage.ObjectHandle, offset, length int64, withSpan bool) (runTime time.Duration, err error) {
+	if withSpan {
+		ctxs, span := tracer().Start(ctx, "rangeread", opKind("download"))
+		ctx = ctxs
+		span.SetAttributes(
+			attribute.KeyValue{Key: "object", Value: attribute.StringValue(o.ObjectName())},
+			attribute.KeyValue{Key: "offset", Value: attribute.Int64Value(offset)},
+			attribute.KeyValue{Key: "mykey", Value: attribute.StringValue(*api)},
+		)
+		defer span.End()
+	}
+
+	ctx, endNested := nestSpans(ctx, *spanDepth)
+	defer endNested()
+
+	defer logOp(ctx, "download", o.ObjectName())(&runTime, &err)
+
+	start := time.Now()
+	defer func() {
+		runTime = time.Since(start)
+	}()
+
+	_, err = readRange(ctx, o, offset, length)
+	return
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// byteSize is a flag.Value holding a byte count. It accepts a plain number
+// or one with a decimal (KB, MB, GB, TB) or binary (KiB, MiB, GiB, TiB) unit.
+type byteSize int64
+
+// sizeFlag defines a byteSize flag, mirroring flag.Int64.
+func sizeFlag(name string, value int64, usage string) *byteSize {
+	b := byteSize(value)
+	flag.Var(&b, name, usage)
+	return &b
+}
+
+func (b *byteSize) String() string {
+	if b == nil {
+		return "0"
+	}
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *byteSize) Set(s string) error {
+	n, err := parseSize(s)
+	if err != nil {
+		return err
+	}
+	*b = byteSize(n)
+	return nil
+}
+
+var sizeUnits = []struct {
+	suffix string
+	mult   int64
+}{
+	// Longest suffixes first so "MiB" isn't matched as "B".
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"TiB", 1 << 40},
+	{"KB", 1000},
+	{"MB", 1000 * 1000},
+	{"GB", 1000 * 1000 * 1000},
+	{"TB", 1000 * 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+// parseSize parses a byte count such as "1048576", "10MiB" or "1GB".
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	mult := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			mult = u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("negative size %q", s)
+	}
+	if n > math.MaxInt64/mult {
+		return 0, fmt.Errorf("size too large %q", s)
+	}
+	return n * mult, nil
+}
+
+// parseSizeRamp parses a ramp of object sizes such as "1MiB..1GiB x2",
+// which doubles from 1MiB up to and including 1GiB, or "1MiB..10MiB +1MiB",
+// which steps by 1MiB. A ramp with no step doubles.
+func parseSizeRamp(s string) ([]int64, error) {
+	bounds, step, _ := strings.Cut(strings.TrimSpace(s), " ")
+	lo, hi, ok := strings.Cut(bounds, "..")
+	if !ok {
+		return nil, fmt.Errorf("invalid size ramp %q: want START..END [xFACTOR|+STEP]", s)
+	}
+	start, err := parseSize(lo)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseSize(hi)
+	if err != nil {
+		return nil, err
+	}
+	if start <= 0 || end < start {
+		return nil, fmt.Errorf("invalid size ramp %q: need 0 < START <= END", s)
+	}
+
+	// next returns the size after n, or false once that would pass end; it
+	// checks before stepping so sizes near MaxInt64 can't wrap.
+	next := func(n int64) (int64, bool) { return n * 2, n <= end/2 }
+	switch step = strings.TrimSpace(step); {
+	case step == "":
+	case strings.HasPrefix(step, "x"):
+		f, err := strconv.ParseInt(step[1:], 10, 64)
+		if err != nil || f < 2 {
+			return nil, fmt.Errorf("invalid size ramp factor %q", step)
+		}
+		next = func(n int64) (int64, bool) { return n * f, n <= end/f }
+	case strings.HasPrefix(step, "+"):
+		inc, err := parseSize(step[1:])
+		if err != nil || inc <= 0 {
+			return nil, fmt.Errorf("invalid size ramp step %q", step)
+		}
+		next = func(n int64) (int64, bool) { return n + inc, n <= end-inc }
+	default:
+		return nil, fmt.Errorf("invalid size ramp step %q: want xFACTOR or +STEP", step)
+	}
+
+	var sizes []int64
+	for n, ok := start, true; ok; n, ok = next(n) {
+		sizes = append(sizes, n)
+	}
+	return sizes, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// slowSpanFilter is a span processor that holds on to the spans under each
+// local root until that root ends, and only passes them on to next if the
+// root took at least threshold. Everything else is dropped and counted.
+// The root's decision is kept for decisionTTL so spans that end after it,
+// such as one started from an ended span's context, follow it too. Spans
+// are grouped by local root rather than trace, as every root of a run shares
+// the -traceparent trace.
+type slowSpanFilter struct {
+	next      sdktrace.SpanProcessor
+	threshold time.Duration
+
+	mu         sync.Mutex
+	roots      localRoots
+	pending    map[trace.SpanID][]sdktrace.ReadOnlySpan
+	decided    map[trace.SpanID]rootDecision
+	suppressed int
+	// unjudged counts spans dropped at Shutdown because their root never
+	// ended.
+	unjudged int
+}
+
+// rootDecision is whether a local root was slow enough to export, and when
+// that was decided.
+type rootDecision struct {
+	slow bool
+	at   time.Time
+}
+
+const decisionTTL = 10 * time.Minute
+
+func newSlowSpanFilter(next sdktrace.SpanProcessor, threshold time.Duration) *slowSpanFilter {
+	return &slowSpanFilter{
+		next:      next,
+		threshold: threshold,
+		roots:     localRoots{},
+		pending:   map[trace.SpanID][]sdktrace.ReadOnlySpan{},
+		decided:   map[trace.SpanID]rootDecision{},
+	}
+}
+
+func (f *slowSpanFilter) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	f.mu.Lock()
+	f.roots.add(s)
+	f.mu.Unlock()
+	f.next.OnStart(parent, s)
+}
+
+func (f *slowSpanFilter) OnEnd(s sdktrace.ReadOnlySpan) {
+	id := s.SpanContext().SpanID()
+
+	f.mu.Lock()
+	var (
+		spans []sdktrace.ReadOnlySpan
+		slow  bool
+	)
+	if root := f.roots.of(id); root != id {
+		d, ok := f.decided[root]
+		if !ok {
+			// Not the local root; wait for the root to decide.
+			f.pending[root] = append(f.pending[root], s)
+			f.mu.Unlock()
+			return
+		}
+		// The root has already ended; follow its decision.
+		spans, slow = []sdktrace.ReadOnlySpan{s}, d.slow
+	} else {
+		spans = append(f.pending[id], s)
+		delete(f.pending, id)
+		slow = s.EndTime().Sub(s.StartTime()) >= f.threshold
+		now := time.Now()
+		for root, d := range f.decided {
+			if now.Sub(d.at) > decisionTTL {
+				delete(f.decided, root)
+				f.roots.drop(root)
+			}
+		}
+		f.decided[id] = rootDecision{slow: slow, at: now}
+	}
+	if !slow {
+		f.suppressed += len(spans)
+	}
+	f.mu.Unlock()
+
+	if slow {
+		for _, s := range spans {
+			f.next.OnEnd(s)
+		}
+	}
+}
+
+func (f *slowSpanFilter) Shutdown(ctx context.Context) error {
+	f.mu.Lock()
+	// Spans whose root never ended can't be judged; they aren't counted as
+	// too fast.
+	for id, spans := range f.pending {
+		f.unjudged += len(spans)
+		delete(f.pending, id)
+	}
+	f.mu.Unlock()
+	return f.next.Shutdown(ctx)
+}
+
+func (f *slowSpanFilter) ForceFlush(ctx context.Context) error {
+	return f.next.ForceFlush(ctx)
+}
+
+// suppressedCount returns how many spans were dropped for being too fast.
+func (f *slowSpanFilter) suppressedCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.suppressed
+}
+
+// unjudgedCount returns how many spans were dropped at Shutdown with their
+// root still open.
+func (f *slowSpanFilter) unjudgedCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.unjudged
+}
+
+// localRoots maps each started span to the local root it runs under: the
+// span itself if it has no parent or a remote one, else its parent's root.
+type localRoots map[trace.SpanID]trace.SpanID
+
+// add records the local root of s.
+func (r localRoots) add(s sdktrace.ReadOnlySpan) {
+	id, root := s.SpanContext().SpanID(), s.SpanContext().SpanID()
+	if p := s.Parent(); p.IsValid() && !p.IsRemote() {
+		root = r.of(p.SpanID())
+	}
+	r[id] = root
+}
+
+// of returns the local root of the span id, or id itself if it wasn't
+// recorded.
+func (r localRoots) of(id trace.SpanID) trace.SpanID {
+	if root, ok := r[id]; ok {
+		return root
+	}
+	return id
+}
+
+// drop forgets every span under root.
+func (r localRoots) drop(root trace.SpanID) {
+	for id, rt := range r {
+		if rt == root {
+			delete(r, id)
+		}
+	}
+}
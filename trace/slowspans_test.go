@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestSlowSpanFilterRootsUnderRemoteParent runs a fast and a slow root under
+// one remote parent, as -traceparent does, and checks each root's children
+// follow their own root's decision rather than whichever root ended first.
+func TestSlowSpanFilterRootsUnderRemoteParent(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	filter := newSlowSpanFilter(rec, 50*time.Millisecond)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(filter))
+	tr := tp.Tracer("test")
+
+	remote := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), remote)
+
+	fastCtx, fast := tr.Start(ctx, "fast")
+	slowCtx, slow := tr.Start(ctx, "slow")
+	_, slowChild := tr.Start(slowCtx, "slow-child")
+	_, fastChild := tr.Start(fastCtx, "fast-child")
+	fastChild.End()
+	fast.End()
+	// The fast root has decided; the slow root's child must still wait for
+	// its own root.
+	slowChild.End()
+	time.Sleep(60 * time.Millisecond)
+	slow.End()
+	tp.Shutdown(context.Background())
+
+	var got []string
+	for _, s := range rec.Ended() {
+		got = append(got, s.Name())
+	}
+	slices.Sort(got)
+	if want := []string{"slow", "slow-child"}; !slices.Equal(got, want) {
+		t.Errorf("exported %q, want %q", got, want)
+	}
+	if n := filter.suppressedCount(); n != 2 {
+		t.Errorf("suppressed %d spans, want 2", n)
+	}
+	if n := filter.unjudgedCount(); n != 0 {
+		t.Errorf("unjudged %d spans, want 0", n)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"google.golang.org/api/googleapi"
+)
+
+// smallWrites writes an -object-size object in -write-unit pieces, ending
+// each piece at a chunk boundary so it goes out on its own, and reports the
+// write throughput and how many requests (JSON API) or messages (gRPC) the
+// upload took. Chunks can't be smaller than 256KiB, so smaller units are
+// batched up to that. With -flush-writes on grpc-dp the object is appendable
+// and each piece is flushed instead, which has no minimum.
+func smallWrites(ctx context.Context) error {
+	unit, size := int64(*writeUnit), int64(*objectSize)
+	if unit <= 0 {
+		return fmt.Errorf("-write-unit must be positive")
+	}
+	if *flushWrites && *api != dp {
+		return fmt.Errorf("-flush-writes requires -api %s", dp)
+	}
+
+	name := fmt.Sprintf("%s%s_%s", *downscopePrefix, "smallwrites", uuid.New().String())
+	w := client.Bucket(*bucketFlag).Object(name).If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+	if *flushWrites {
+		w.Append = true
+		w.FinalizeOnClose = true
+		fmt.Printf("small-writes: %d byte units, flushed after each\n", unit)
+	} else {
+		const chunkMin = googleapi.MinUploadChunkSize
+		w.ChunkSize = int((unit + chunkMin - 1) / chunkMin * chunkMin)
+		fmt.Printf("small-writes: %d byte units, chunk size %d\n", unit, w.ChunkSize)
+	}
+	uploadRPCs.setPhase("small-writes")
+
+	var (
+		src     = budget.uploadReader(payload())
+		buf     = make([]byte, unit)
+		flushes []time.Duration
+		start   = time.Now()
+	)
+	for written := int64(0); written < size; {
+		n := min(unit, size-written)
+		if _, err := io.ReadFull(src, buf[:n]); err != nil {
+			w.Close()
+			return fmt.Errorf("payload: %w", err)
+		}
+		if _, err := w.Write(buf[:n]); err != nil {
+			w.Close()
+			results.fail("small-writes", time.Since(start), err)
+			return fmt.Errorf("write at %d: %w", written, err)
+		}
+		written += n
+		if *flushWrites {
+			t := time.Now()
+			if _, err := w.Flush(); err != nil {
+				w.Close()
+				results.fail("small-writes", time.Since(start), err)
+				return fmt.Errorf("flush at %d: %w", written, err)
+			}
+			flushes = append(flushes, time.Since(t))
+		}
+	}
+	if err := w.Close(); err != nil {
+		results.fail("small-writes", time.Since(start), err)
+		return fmt.Errorf("w.Close: %w", err)
+	}
+	d := time.Since(start)
+	recordCreated(name, w.Attrs().Generation)
+	results.record("small-writes", d, size)
+
+	units := (size + unit - 1) / unit
+	requests, what := len(uploadRPCs.get("small-writes data")), "requests"
+	if *api == dp {
+		requests, what = len(uploadRPCs.get("small-writes message")), "messages"
+	}
+	fmt.Printf("small-writes: %d bytes in %d units in %v (%.2f MiB/s), %d %s\n", size, units, d, mibps(size, d), requests, what)
+	if len(flushes) > 0 {
+		fmt.Printf("flush latency: %s\n", latencySummary(flushes))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// nestSpans starts a chain of n nested decoy spans under ctx for -span-depth
+// and returns a context holding the innermost one, and a func ending them
+// all.
+func nestSpans(ctx context.Context, n int) (context.Context, func()) {
+	spans := make([]trace.Span, 0, n)
+	for i := range n {
+		var s trace.Span
+		ctx, s = tracer().Start(ctx, fmt.Sprintf("depth-%d", i+1))
+		spans = append(spans, s)
+	}
+	return ctx, func() {
+		for i := len(spans) - 1; i >= 0; i-- {
+			spans[i].End()
+		}
+	}
+}
+
+// depthTracker is a SpanProcessor recording how deep in its trace each span
+// starts, roots being at depth 1, and the deepest seen. The depths of the
+// spans under a local root are dropped when it ends; other roots of the same
+// -traceparent trace may still be open.
+type depthTracker struct {
+	mu     sync.Mutex
+	depths map[trace.SpanID]int
+	roots  localRoots
+
+	deepest  atomic.Int64
+	deepName atomic.Value
+}
+
+func (d *depthTracker) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	sc := s.SpanContext()
+	depth := 1
+	d.mu.Lock()
+	if d.depths == nil {
+		d.depths = map[trace.SpanID]int{}
+		d.roots = localRoots{}
+	}
+	if p := trace.SpanContextFromContext(parent); p.IsValid() {
+		if pd, ok := d.depths[p.SpanID()]; ok {
+			depth = pd + 1
+		} else {
+			// A remote parent, e.g. from -traceparent.
+			depth = 2
+		}
+	}
+	d.depths[sc.SpanID()] = depth
+	d.roots.add(s)
+	d.mu.Unlock()
+	for {
+		cur := d.deepest.Load()
+		if int64(depth) <= cur {
+			break
+		}
+		if d.deepest.CompareAndSwap(cur, int64(depth)) {
+			d.deepName.Store(s.Name())
+			break
+		}
+	}
+}
+
+func (d *depthTracker) OnEnd(s sdktrace.ReadOnlySpan) {
+	id := s.SpanContext().SpanID()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.roots.of(id) != id {
+		return
+	}
+	for span, root := range d.roots {
+		if root == id {
+			delete(d.depths, span)
+		}
+	}
+	d.roots.drop(id)
+}
+
+func (d *depthTracker) Shutdown(context.Context) error   { return nil }
+func (d *depthTracker) ForceFlush(context.Context) error { return nil }
+
+func (d *depthTracker) String() string {
+	name, _ := d.deepName.Load().(string)
+	return fmt.Sprintf("deepest span %q at depth %d", name, d.deepest.Load())
+}
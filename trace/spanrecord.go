@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// spanRecord is the on-disk form of a span written by -record-spans.
+type spanRecord struct {
+	Name       string            `json:"name"`
+	Scope      string            `json:"scope"`
+	TraceID    string            `json:"trace_id"`
+	SpanID     string            `json:"span_id"`
+	ParentID   string            `json:"parent_span_id,omitempty"`
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// writeSpanRecords writes the spans held by mem to path, one JSON record per
+// line.
+func writeSpanRecords(path string, mem *tracetest.InMemoryExporter) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, r := range spanRecords(mem) {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// spanRecords converts the spans held by mem to records.
+func spanRecords(mem *tracetest.InMemoryExporter) []spanRecord {
+	var recs []spanRecord
+	for _, s := range mem.GetSpans() {
+		r := spanRecord{
+			Name:    s.Name,
+			Scope:   s.InstrumentationScope.Name,
+			TraceID: s.SpanContext.TraceID().String(),
+			SpanID:  s.SpanContext.SpanID().String(),
+			Start:   s.StartTime,
+			End:     s.EndTime,
+		}
+		if s.Parent.IsValid() {
+			r.ParentID = s.Parent.SpanID().String()
+		}
+		for _, kv := range s.Attributes {
+			if r.Attributes == nil {
+				r.Attributes = map[string]string{}
+			}
+			r.Attributes[string(kv.Key)] = kv.Value.Emit()
+		}
+		recs = append(recs, r)
+	}
+	return recs
+}
+
+func readSpanRecords(path string) ([]spanRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var recs []spanRecord
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var r spanRecord
+		if err := dec.Decode(&r); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		recs = append(recs, r)
+	}
+	return recs, nil
+}
+
+// spanExpectation describes a span the upload-download run must produce.
+type spanExpectation struct {
+	name string
+	// parents lists the acceptable parent span names; "" is a root span.
+	parents []string
+	// attrs must be present on the span.
+	attrs []string
+	// noAttrs requires the span to carry no attributes at all.
+	noAttrs bool
+	// optional spans are only created with -add-spans.
+	optional bool
+}
+
+var uploadDownloadSpans = []spanExpectation{
+	{name: "uploada", parents: []string{""}, attrs: []string{"object", "mykey"}, optional: true},
+	{name: "downloads", parents: []string{""}, attrs: []string{"object", "mykey"}, optional: true},
+	{name: "user-span-1", parents: []string{"downloads", ""}, attrs: []string{"object", "mykey"}},
+	// download() starts user-span-2 from the context holding the ended
+	// user-span-1 and then sets its attributes on user-span-1, where they're
+	// dropped. That's the behaviour being reproduced, so pin it.
+	{name: "user-span-2", parents: []string{"user-span-1"}, noAttrs: true},
+}
+
+// validateSpans checks the spans recorded in path against the shape of the
+// upload-download run, returning every mismatch found.
+func validateSpans(path string) error {
+	recs, err := readSpanRecords(path)
+	if err != nil {
+		return err
+	}
+	if problems := checkSpanShape(recs); len(problems) > 0 {
+		return fmt.Errorf("%s: %d span problems:\n  %s", path, len(problems), strings.Join(problems, "\n  "))
+	}
+	fmt.Printf("%s: %d spans match the upload-download shape\n", path, len(recs))
+	return nil
+}
+
+// validateReproduction checks the spans of the run just made with
+// -validate-reproduction, held by mem, against the upload-download shape.
+func validateReproduction(mem *tracetest.InMemoryExporter) error {
+	recs := spanRecords(mem)
+	if problems := checkSpanShape(recs); len(problems) > 0 {
+		return fmt.Errorf("reproduction: %d span problems:\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+	fmt.Printf("reproduction: %d spans match the upload-download shape\n", len(recs))
+	return nil
+}
+
+// reproductionSkipped returns why the spans of this run can't be checked
+// against the upload-download shape, or "" if they can. -slow-threshold and
+// -sample-per-op drop spans the shape requires, -span-depth puts decoys
+// between them and a -traceparent parent is never recorded.
+func reproductionSkipped() string {
+	switch {
+	case *spanDepth > 0:
+		return "-span-depth nests the spans under decoys"
+	case *slowThreshold > 0:
+		return "-slow-threshold filters spans"
+	case *samplePerOp != "":
+		return "-sample-per-op samples spans"
+	case *traceparent != "":
+		return "-traceparent roots the spans in a remote trace"
+	}
+	return ""
+}
+
+// checkSpanShape returns every way recs differ from the spans the
+// upload-download run must produce: the parent, attributes and timing of
+// each.
+func checkSpanShape(recs []spanRecord) []string {
+	byID := map[string]spanRecord{}
+	byName := map[string][]spanRecord{}
+	for _, r := range recs {
+		byID[r.TraceID+"/"+r.SpanID] = r
+		byName[r.Name] = append(byName[r.Name], r)
+	}
+
+	var problems []string
+	for _, want := range uploadDownloadSpans {
+		got := byName[want.name]
+		if len(got) == 0 {
+			if !want.optional {
+				problems = append(problems, fmt.Sprintf("missing span %q", want.name))
+			}
+			continue
+		}
+		for _, r := range got {
+			parent := ""
+			if r.ParentID != "" {
+				p, ok := byID[r.TraceID+"/"+r.ParentID]
+				if !ok {
+					problems = append(problems, fmt.Sprintf("span %q: parent %s not recorded", r.Name, r.ParentID))
+					continue
+				}
+				parent = p.Name
+				// A child of a still-open span must end within it. user-span-2's
+				// parent has ended before it starts, which is checked below.
+				if parent != "user-span-1" && (r.Start.Before(p.Start) || r.End.After(p.End)) {
+					problems = append(problems, fmt.Sprintf("span %q: runs outside its parent %q", r.Name, parent))
+				}
+			}
+			if !slices.Contains(want.parents, parent) {
+				problems = append(problems, fmt.Sprintf("span %q: parent is %q, want one of %q", r.Name, parent, want.parents))
+			}
+			if parent == "user-span-1" {
+				if p := byID[r.TraceID+"/"+r.ParentID]; r.Start.Before(p.End) {
+					problems = append(problems, fmt.Sprintf("span %q: starts before user-span-1 ends, want the two not to overlap", r.Name))
+				}
+			}
+			for _, k := range want.attrs {
+				if _, ok := r.Attributes[k]; !ok {
+					problems = append(problems, fmt.Sprintf("span %q: missing attribute %q", r.Name, k))
+				}
+			}
+			if want.noAttrs && len(r.Attributes) > 0 {
+				problems = append(problems, fmt.Sprintf("span %q: has attributes %v, want none", r.Name, r.Attributes))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestValidateReproduction runs an upload and download with -add-spans
+// against the testbench, spans held in memory, and checks them against the
+// upload-download shape as -validate-reproduction does. download sleeps
+// between its reads, so this takes over 100s.
+func TestValidateReproduction(t *testing.T) {
+	b := testbenchBucket(t)
+	setFlag(t, "bucket", b.BucketName())
+	mem := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(mem))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	ctx := context.Background()
+	_, o, err := upload(ctx, 4096, true)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	defer o.Delete(ctx)
+	if _, err := download(ctx, o, 4096, true); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	tp.Shutdown(ctx)
+
+	if err := validateReproduction(mem); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReproductionSkipped(t *testing.T) {
+	if why := reproductionSkipped(); why != "" {
+		t.Fatalf("reproductionSkipped() = %q with default flags, want \"\"", why)
+	}
+	for _, f := range [][2]string{
+		{"slow-threshold", "1s"},
+		{"span-depth", "3"},
+		{"sample-per-op", "upload=0.5"},
+		{"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	} {
+		t.Run(f[0], func(t *testing.T) {
+			setFlag(t, f[0], f[1])
+			if reproductionSkipped() == "" {
+				t.Errorf("reproductionSkipped() = \"\" with -%s %s, want a reason", f[0], f[1])
+			}
+		})
+	}
+}
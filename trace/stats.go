@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// percentile returns the p'th percentile (0-100) of sorted using the
+// nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// latencySummary formats the distribution of ds as percentiles.
+func latencySummary(ds []time.Duration) string {
+	if len(ds) == 0 {
+		return "no samples"
+	}
+	sorted := slices.Clone(ds)
+	slices.Sort(sorted)
+	return fmt.Sprintf("n=%d p50=%v p90=%v p99=%v max=%v",
+		len(sorted), percentile(sorted, 50), percentile(sorted, 90), percentile(sorted, 99), sorted[len(sorted)-1])
+}
+
+// latencyColumns is latencySummary as tab separated n, p50, p90, p99 and max
+// columns for a tabwriter table.
+func latencyColumns(ds []time.Duration) string {
+	if len(ds) == 0 {
+		return "0\t-\t-\t-\t-"
+	}
+	sorted := slices.Clone(ds)
+	slices.Sort(sorted)
+	return fmt.Sprintf("%d\t%v\t%v\t%v\t%v",
+		len(sorted), percentile(sorted, 50), percentile(sorted, 90), percentile(sorted, 99), sorted[len(sorted)-1])
+}
+
+// jitter is the mean and spread of a set of latencies. CV, the standard
+// deviation over the mean, is high when latency is inconsistent even if the
+// percentiles look good.
+type jitter struct {
+	MeanMS   float64 `json:"mean_ms"`
+	StddevMS float64 `json:"stddev_ms"`
+	CV       float64 `json:"cv"`
+}
+
+// latencyJitter returns the mean, sample standard deviation and coefficient
+// of variation of ds.
+func latencyJitter(ds []time.Duration) jitter {
+	if len(ds) == 0 {
+		return jitter{}
+	}
+	var sum float64
+	for _, d := range ds {
+		sum += float64(d)
+	}
+	mean := sum / float64(len(ds))
+	var sq float64
+	for _, d := range ds {
+		sq += (float64(d) - mean) * (float64(d) - mean)
+	}
+	stddev := 0.0
+	if len(ds) > 1 {
+		stddev = math.Sqrt(sq / float64(len(ds)-1))
+	}
+	j := jitter{MeanMS: mean / float64(time.Millisecond), StddevMS: stddev / float64(time.Millisecond)}
+	if mean > 0 {
+		j.CV = stddev / mean
+	}
+	return j
+}
+
+func (j jitter) String() string {
+	return fmt.Sprintf("mean=%.3fms stddev=%.3fms cv=%.3f", j.MeanMS, j.StddevMS, j.CV)
+}
+
+// tCritical95 is the two-sided 95% critical value of Student's t for 1 to 30
+// degrees of freedom; past 30 the normal 1.96 is close enough.
+var tCritical95 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// ci95 returns the mean of ds and the half width of its 95% confidence
+// interval. With fewer than two samples the interval is unbounded.
+func ci95(ds []time.Duration) (mean, half time.Duration) {
+	j := latencyJitter(ds)
+	mean = time.Duration(j.MeanMS * float64(time.Millisecond))
+	if len(ds) < 2 {
+		return mean, time.Duration(math.MaxInt64)
+	}
+	t := 1.96
+	if df := len(ds) - 1; df <= len(tCritical95) {
+		t = tCritical95[df-1]
+	}
+	half = time.Duration(t * j.StddevMS * float64(time.Millisecond) / math.Sqrt(float64(len(ds))))
+	return mean, half
+}
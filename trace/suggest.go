@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var apis = []string{http1, http2, dp}
+
+// validateAPI lower-cases -api and checks it against the known transports,
+// suggesting the closest one on a typo.
+func validateAPI() error {
+	*api = strings.ToLower(*api)
+	if slices.Contains(apis, *api) {
+		return nil
+	}
+	msg := fmt.Sprintf("invalid -api %q; valid options are %s", *api, strings.Join(apis, ", "))
+	if s := closest(*api, apis); s != "" {
+		msg += fmt.Sprintf(". Did you mean %q?", s)
+	}
+	return errors.New(msg)
+}
+
+// closest returns the option nearest to s by edit distance, or "" if none is
+// close enough to be a plausible typo.
+func closest(s string, options []string) string {
+	best, bestDist := "", len(s)/2+2
+	for _, o := range options {
+		if d := levenshtein(strings.ToLower(s), o); d < bestDist {
+			best, bestDist = o, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
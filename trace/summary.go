@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// summary is the machine readable result of a run, written by -summary-out.
+type summary struct {
+	mu          sync.Mutex
+	iteration   int
+	subscribers []func(opResult)
+
+	Config            runConfig  `json:"config"`
+	Op                string     `json:"op"`
+	Results           []opResult `json:"results"`
+	BytesTransferred  int64      `json:"bytes_transferred"`
+	StopReason        string     `json:"stop_reason"`
+	ChecksumsDisabled bool       `json:"checksums_disabled"`
+	GC                gcReport   `json:"gc"`
+	// ColdStartMS is the iteration 0 latency of upload and download.
+	ColdStartMS map[string]float64 `json:"cold_start_ms,omitempty"`
+	// Jitter is the spread of the same ops' steady state latency.
+	Jitter map[string]jitter `json:"jitter,omitempty"`
+	// PeakGoroutines is the most goroutines seen under -max-goroutines.
+	PeakGoroutines int64 `json:"peak_goroutines,omitempty"`
+	// Wire compares wire bytes with payload bytes under -wire-bytes.
+	Wire *wireReport `json:"wire,omitempty"`
+}
+
+// opResult is the outcome of a single operation within a run.
+type opResult struct {
+	Name       string        `json:"name"`
+	Iteration  int           `json:"iteration"`
+	End        time.Time     `json:"end"`
+	Duration   time.Duration `json:"-"`
+	DurationMS float64       `json:"duration_ms"`
+	Bytes      int64         `json:"bytes,omitempty"`
+	// Worker is the goroutine of a worker pool that ran the op, or 0.
+	Worker int `json:"worker"`
+	// Err is set only on results passed to subscribers by fail.
+	Err string `json:"err,omitempty"`
+}
+
+func (s *summary) record(name string, d time.Duration, bytes int64) {
+	s.recordOn(0, name, d, bytes)
+}
+
+// recordOn is record for an op run by worker, one of a pool's goroutines
+// numbered from 0.
+func (s *summary) recordOn(worker int, name string, d time.Duration, bytes int64) {
+	s.recordAt(s.currentIteration(), worker, name, d, bytes)
+}
+
+// recordAt is recordOn for an op belonging to an iteration other than the
+// current one.
+func (s *summary) recordAt(iteration, worker int, name string, d time.Duration, bytes int64) {
+	s.mu.Lock()
+	r := opResult{
+		Name:       name,
+		Iteration:  iteration,
+		Worker:     worker,
+		End:        time.Now(),
+		Duration:   d,
+		DurationMS: float64(d) / float64(time.Millisecond),
+		Bytes:      bytes,
+	}
+	s.Results = append(s.Results, r)
+	subs := s.subscribers
+	s.mu.Unlock()
+
+	for _, fn := range subs {
+		fn(r)
+	}
+}
+
+// fail tells subscribers an op failed after d. Failures aren't kept in
+// Results, so they never count towards the totals.
+func (s *summary) fail(name string, d time.Duration, err error) {
+	s.failOn(0, name, d, err)
+}
+
+// failOn is fail for an op run by worker.
+func (s *summary) failOn(worker int, name string, d time.Duration, err error) {
+	s.failAt(s.currentIteration(), worker, name, d, err)
+}
+
+// failAt is failOn for an op of the given iteration.
+func (s *summary) failAt(iteration, worker int, name string, d time.Duration, err error) {
+	s.mu.Lock()
+	r := opResult{
+		Name:       name,
+		Iteration:  iteration,
+		Worker:     worker,
+		End:        time.Now(),
+		Duration:   d,
+		DurationMS: float64(d) / float64(time.Millisecond),
+		Err:        err.Error(),
+	}
+	subs := s.subscribers
+	s.mu.Unlock()
+
+	for _, fn := range subs {
+		fn(r)
+	}
+}
+
+func (s *summary) currentIteration() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.iteration
+}
+
+// subscribe calls fn with every result as it's recorded.
+func (s *summary) subscribe(fn func(opResult)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// setIteration stamps results recorded from now on with iteration i.
+func (s *summary) setIteration(i int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.iteration = i
+}
+
+func (s *summary) totalTime() time.Duration {
+	var total time.Duration
+	for _, r := range s.Results {
+		total += r.Duration
+	}
+	return total
+}
+
+// opTotal aggregates the results sharing a name.
+type opTotal struct {
+	name     string
+	count    int
+	bytes    int64
+	duration time.Duration
+}
+
+// totals returns per-name aggregates in order of first appearance.
+func (s *summary) totals() []opTotal {
+	var out []opTotal
+	idx := map[string]int{}
+	for _, r := range s.Results {
+		i, ok := idx[r.Name]
+		if !ok {
+			i = len(out)
+			idx[r.Name] = i
+			out = append(out, opTotal{name: r.Name})
+		}
+		out[i].count++
+		out[i].bytes += r.Bytes
+		out[i].duration += r.Duration
+	}
+	return out
+}
+
+// split separates the results named name, or name/ followed by a variant
+// such as an upload strategy, into the first one of iteration 0, which pays
+// for DNS, TLS and connection setup, and the durations of the rest. With
+// excludeCold the rest omits iteration 0 entirely.
+func (s *summary) split(name string, excludeCold bool) (cold *opResult, steady []time.Duration) {
+	for i, r := range s.Results {
+		switch {
+		case r.Name != name && !strings.HasPrefix(r.Name, name+"/"):
+		case r.Iteration == 0 && cold == nil:
+			cold = &s.Results[i]
+			if !excludeCold {
+				steady = append(steady, r.Duration)
+			}
+		case r.Iteration == 0 && excludeCold:
+		default:
+			steady = append(steady, r.Duration)
+		}
+	}
+	return cold, steady
+}
+
+// secretFlags hold keys that must never be written out with the config.
+var secretFlags = map[string]bool{
+	"encryption-key": true,
+	"decryption-key": true,
+}
+
+// redactFlag returns the value of flag name as it may be recorded: secret
+// flags are masked and any credentials in -proxy dropped.
+func redactFlag(name, value string) string {
+	if value == "" {
+		return value
+	}
+	if secretFlags[name] {
+		return "REDACTED"
+	}
+	if name == "proxy" {
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			u.User = url.User("REDACTED")
+			return u.String()
+		}
+	}
+	return value
+}
+
+// runConfig documents how a run was produced.
+type runConfig struct {
+	Version    string            `json:"version"`
+	Bucket     string            `json:"bucket"`
+	API        string            `json:"api"`
+	ObjectSize int64             `json:"object_size"`
+	Flags      map[string]string `json:"flags"`
+	Env        map[string]string `json:"env,omitempty"`
+}
+
+// configEnv lists the environment variables that change the client's
+// behaviour and so are recorded with the configuration.
+var configEnv = []string{
+	"GOOGLE_CLOUD_ENABLE_DIRECT_PATH_XDS",
+	"STORAGE_EMULATOR_HOST",
+	"GOOGLE_CLOUD_PROJECT",
+}
+
+// effectiveConfig returns every flag's value after defaults are applied,
+// along with the relevant environment.
+func effectiveConfig() runConfig {
+	c := runConfig{
+		Version:    toolVersion(),
+		Bucket:     *bucketFlag,
+		API:        *api,
+		ObjectSize: int64(*objectSize),
+		Flags:      map[string]string{},
+		Env:        map[string]string{},
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		c.Flags[f.Name] = redactFlag(f.Name, f.Value.String())
+	})
+	for _, k := range configEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			c.Env[k] = v
+		}
+	}
+	return c
+}
+
+// toolVersion returns the module version and VCS revision the binary was
+// built from.
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	v := info.Main.Version
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			v += " " + s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				v += "-dirty"
+			}
+		}
+	}
+	return v
+}
+
+// mibps returns the throughput of moving bytes in d, in MiB/s.
+func mibps(bytes int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes) / (1 << 20) / d.Seconds()
+}
+
+func writeJSON(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// withinTargetCI reports whether the 95% confidence interval of the mean of
+// ds is within -target-ci percent of the mean.
+func withinTargetCI(ds []time.Duration) bool {
+	mean, half := ci95(ds)
+	return len(ds) >= 2 && float64(half) <= *targetCI/100*float64(mean)
+}
+
+// reportTargetCI prints the confidence interval upload-download reached for
+// each op and whether that met -target-ci.
+func reportTargetCI(uploads, downloads []time.Duration, reached bool) {
+	verdict := "reached"
+	if !reached {
+		verdict = fmt.Sprintf("not reached within -max-iterations %d", *maxIterations)
+	}
+	fmt.Printf("target-ci %.1f%%: %s after %d iterations\n", *targetCI, verdict, len(uploads))
+	for _, op := range []struct {
+		name string
+		ds   []time.Duration
+	}{{"upload", uploads}, {"download", downloads}} {
+		mean, half := ci95(op.ds)
+		if len(op.ds) < 2 {
+			fmt.Printf("target-ci: %s mean %v, too few samples for an interval\n", op.name, mean)
+			continue
+		}
+		fmt.Printf("target-ci: %s mean %v ± %v (%.1f%%)\n", op.name, mean, half, 100*float64(half)/float64(mean))
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// testbenchBucket returns a fresh bucket on the storage testbench named by
+// STORAGE_EMULATOR_HOST, skipping the test when it is unset. It also sets
+// the package globals the ops expect main to have set up.
+func testbenchBucket(t *testing.T) *storage.BucketHandle {
+	t.Helper()
+	if os.Getenv("STORAGE_EMULATOR_HOST") == "" {
+		t.Skip("STORAGE_EMULATOR_HOST not set; start the storage testbench to run this test")
+	}
+	ctx := context.Background()
+	c, err := storage.NewClient(ctx)
+	if err != nil {
+		t.Fatalf("storage.NewClient: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	client = c
+	budget = newTransferBudget(0, func(error) {})
+
+	b := c.Bucket(fmt.Sprintf("trace-test-%d", time.Now().UnixNano()))
+	if err := b.Create(ctx, "test", nil); err != nil {
+		t.Fatalf("create bucket: %v", err)
+	}
+	return b
+}
+
+// setFlag sets a flag for the duration of the test.
+func setFlag(t *testing.T, name, value string) {
+	t.Helper()
+	old := flag.Lookup(name).Value.String()
+	if err := flag.Set(name, value); err != nil {
+		t.Fatalf("flag.Set(%q, %q): %v", name, value, err)
+	}
+	t.Cleanup(func() { flag.Set(name, old) })
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// runStart is when the process started; timeline times are relative to it.
+var runStart = time.Now()
+
+// timelineOp is one op in the -timeline-out file, drawn as a bar from
+// StartMS to EndMS in row Worker of a Gantt chart.
+type timelineOp struct {
+	Op        string  `json:"op"`
+	Iteration int     `json:"iteration"`
+	Worker    int     `json:"worker"`
+	StartMS   float64 `json:"start_ms"`
+	EndMS     float64 `json:"end_ms"`
+	Bytes     int64   `json:"bytes,omitempty"`
+}
+
+type timeline struct {
+	RunStart time.Time    `json:"run_start"`
+	Workers  int          `json:"workers"`
+	Ops      []timelineOp `json:"ops"`
+}
+
+// buildTimeline lays the recorded results out for a Gantt chart, one row
+// per worker that ran them: a worker pool's goroutine, the -overlap
+// pipeline's upload or download side, or worker 0 for serial ops.
+func buildTimeline(rs []opResult) timeline {
+	ms := func(t time.Time) float64 { return float64(t.Sub(runStart)) / float64(time.Millisecond) }
+	ops := make([]timelineOp, len(rs))
+	workers := 0
+	for i, r := range rs {
+		workers = max(workers, r.Worker+1)
+		ops[i] = timelineOp{
+			Op:        r.Name,
+			Iteration: r.Iteration,
+			Worker:    r.Worker,
+			StartMS:   ms(r.End.Add(-r.Duration)),
+			EndMS:     ms(r.End),
+			Bytes:     r.Bytes,
+		}
+	}
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].StartMS < ops[j].StartMS })
+	return timeline{RunStart: runStart, Workers: workers, Ops: ops}
+}
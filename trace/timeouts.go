@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+var (
+	errConnectTimeout = errors.New("connect-timeout exceeded")
+	errReadTimeout    = errors.New("read-timeout exceeded")
+	errOverallTimeout = errors.New("overall-timeout exceeded")
+)
+
+// dialContext dials with -connect-timeout, logging when it is the timeout
+// that made the dial fail.
+func dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := net.Dialer{Timeout: *connectTimeout, KeepAlive: 30 * time.Second}
+	c, err := d.DialContext(ctx, network, addr)
+	var ne net.Error
+	if err != nil && errors.As(err, &ne) && ne.Timeout() && ctx.Err() == nil {
+		log.Printf("timeout: %v dialing %s after %v", errConnectTimeout, addr, *connectTimeout)
+		return nil, fmt.Errorf("%w: %w", errConnectTimeout, err)
+	}
+	return c, err
+}
+
+// watchOverallTimeout logs when -overall-timeout ends the run's context.
+func watchOverallTimeout(ctx context.Context) {
+	context.AfterFunc(ctx, func() {
+		if errors.Is(context.Cause(ctx), errOverallTimeout) {
+			log.Printf("timeout: %v after %v", errOverallTimeout, *overallTimeout)
+		}
+	})
+}
+
+// newRangeReader opens a range reader whose reads are bounded by
+// -read-timeout: if a single Read gets no data for that long the reader's
+// context is cancelled and the Read fails with errReadTimeout. Time the
+// caller spends between reads doesn't count.
+func newRangeReader(ctx context.Context, o *storage.ObjectHandle, offset, length int64) (io.ReadCloser, error) {
+	if *readTimeout <= 0 {
+		return o.NewRangeReader(ctx, offset, length)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	r, err := o.NewRangeReader(ctx, offset, length)
+	if err != nil {
+		cancel(nil)
+		return nil, err
+	}
+	return &stallReader{r: r, timeout: *readTimeout, cancel: cancel}, nil
+}
+
+// stallReader is the watchdog behind -read-timeout.
+type stallReader struct {
+	r       io.ReadCloser
+	timeout time.Duration
+	cancel  context.CancelCauseFunc
+	fired   atomic.Bool
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	t := time.AfterFunc(s.timeout, func() {
+		s.fired.Store(true)
+		log.Printf("timeout: %v, no data for %v", errReadTimeout, s.timeout)
+		s.cancel(errReadTimeout)
+	})
+	n, err := s.r.Read(p)
+	t.Stop()
+	if err != nil && err != io.EOF && s.fired.Load() {
+		return n, fmt.Errorf("%w: no data for %v: %w", errReadTimeout, s.timeout, err)
+	}
+	return n, err
+}
+
+func (s *stallReader) Close() error {
+	defer s.cancel(nil)
+	return s.r.Close()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tlsMinVersion and tlsCipherSuites are the parsed -tls-min-version and
+// -tls-ciphers.
+var (
+	tlsMinVersion   uint16
+	tlsCipherSuites []uint16
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSFlags sets tlsMinVersion and tlsCipherSuites from the flags.
+// Cipher suites are Go's names for them, e.g.
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. TLS 1.3 suites aren't configurable,
+// so they're rejected rather than silently ignored.
+func parseTLSFlags() error {
+	if *tlsMinVersionFlag != "" {
+		v, ok := tlsVersions[*tlsMinVersionFlag]
+		if !ok {
+			return fmt.Errorf("invalid -tls-min-version %q: want 1.0, 1.1, 1.2 or 1.3", *tlsMinVersionFlag)
+		}
+		tlsMinVersion = v
+	}
+	if *tlsCiphersFlag == "" {
+		return nil
+	}
+	suites := map[string]*tls.CipherSuite{}
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suites[s.Name] = s
+	}
+	for _, name := range strings.Split(*tlsCiphersFlag, ",") {
+		name = strings.TrimSpace(name)
+		s, ok := suites[name]
+		if !ok {
+			return fmt.Errorf("-tls-ciphers: unknown cipher suite %q", name)
+		}
+		if len(s.SupportedVersions) == 1 && s.SupportedVersions[0] == tls.VersionTLS13 {
+			return fmt.Errorf("-tls-ciphers: %s is a TLS 1.3 suite, which can't be configured", name)
+		}
+		tlsCipherSuites = append(tlsCipherSuites, s.ID)
+	}
+	if tlsMinVersion == tls.VersionTLS13 {
+		return fmt.Errorf("-tls-ciphers has no effect with -tls-min-version 1.3")
+	}
+	return nil
+}
+
+// pinTLS applies -tls-min-version and -tls-ciphers to c, which may be nil.
+func pinTLS(c *tls.Config) *tls.Config {
+	if tlsMinVersion == 0 && tlsCipherSuites == nil {
+		return c
+	}
+	if c == nil {
+		c = &tls.Config{}
+	}
+	c.MinVersion = tlsMinVersion
+	c.CipherSuites = tlsCipherSuites
+	return c
+}
+
+// tlsStats counts handshakes by negotiated version and cipher suite.
+type tlsStats struct {
+	mu         sync.Mutex
+	negotiated map[string]int
+}
+
+var handshakes = &tlsStats{negotiated: map[string]int{}}
+
+func (t *tlsStats) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var parts []string
+	for k, n := range t.negotiated {
+		parts = append(parts, fmt.Sprintf("%s (%d handshakes)", k, n))
+	}
+	if len(parts) == 0 {
+		return "no TLS handshakes"
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// tlsTrackingTransport records, through httptrace, the version and cipher
+// suite each new TLS connection negotiated.
+type tlsTrackingTransport struct {
+	next  http.RoundTripper
+	stats *tlsStats
+}
+
+func (t *tlsTrackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		TLSHandshakeDone: func(cs tls.ConnectionState, err error) {
+			if err != nil {
+				return
+			}
+			k := tls.VersionName(cs.Version) + " " + tls.CipherSuiteName(cs.CipherSuite)
+			t.stats.mu.Lock()
+			t.stats.negotiated[k]++
+			t.stats.mu.Unlock()
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	return t.next.RoundTrip(req)
+}
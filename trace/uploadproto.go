@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/stats"
+)
+
+// grpcWriteMethods are the gRPC methods that carry object data.
+var grpcWriteMethods = map[string]bool{
+	"WriteObject":         true,
+	"BidiWriteObject":     true,
+	"StartResumableWrite": true,
+}
+
+type uploadProtocolKey struct{}
+
+// uploadProtocol collects the gRPC methods called under an upload's context
+// for -assert-grpc-upload.
+type uploadProtocol struct {
+	mu      sync.Mutex
+	methods map[string]int
+}
+
+// trackUploadProtocol returns ctx set up to record the gRPC methods the
+// upload made under it calls.
+func trackUploadProtocol(ctx context.Context) (context.Context, *uploadProtocol) {
+	p := &uploadProtocol{methods: map[string]int{}}
+	return context.WithValue(ctx, uploadProtocolKey{}, p), p
+}
+
+// check prints which protocol the upload of object used and fails if it
+// made no gRPC write call, meaning it went over JSON/HTTP instead.
+func (p *uploadProtocol) check(object string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var names []string
+	grpcWrite := false
+	for m, n := range p.methods {
+		names = append(names, fmt.Sprintf("%s x%d", m, n))
+		grpcWrite = grpcWrite || grpcWriteMethods[m]
+	}
+	sort.Strings(names)
+	if !grpcWrite {
+		fmt.Printf("upload %s: protocol JSON/HTTP (gRPC calls: %s)\n", object, strings.Join(names, ", "))
+		return fmt.Errorf("upload %s made no gRPC write call; it fell back to JSON/HTTP", object)
+	}
+	fmt.Printf("upload %s: protocol gRPC (%s)\n", object, strings.Join(names, ", "))
+	return nil
+}
+
+// grpcMethodHandler is a gRPC stats.Handler recording each RPC's method in
+// the uploadProtocol of its context, if any.
+type grpcMethodHandler struct{}
+
+func (grpcMethodHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	if p, ok := ctx.Value(uploadProtocolKey{}).(*uploadProtocol); ok {
+		p.mu.Lock()
+		p.methods[path.Base(info.FullMethodName)]++
+		p.mu.Unlock()
+	}
+	return ctx
+}
+
+func (grpcMethodHandler) HandleRPC(context.Context, stats.RPCStats) {}
+
+func (grpcMethodHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (grpcMethodHandler) HandleConn(context.Context, stats.ConnStats) {}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/stats"
+)
+
+// wireStats counts the bytes the clients sent and received on the wire, to
+// compare against the object payload moved. Over HTTP that's every byte on
+// the TCP connection, so headers, chunk encoding and TLS; over gRPC it's
+// headers, trailers and message framing as reported by gRPC.
+type wireStats struct {
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+var wire wireStats
+
+// wireReport is the -wire-bytes section of the summary.
+type wireReport struct {
+	SentBytes       int64   `json:"sent_bytes"`
+	ReceivedBytes   int64   `json:"received_bytes"`
+	UploadedBytes   int64   `json:"uploaded_bytes"`
+	DownloadedBytes int64   `json:"downloaded_bytes"`
+	SentOverhead    float64 `json:"sent_overhead,omitempty"`
+	ReceiveOverhead float64 `json:"receive_overhead,omitempty"`
+}
+
+// report compares the wire bytes with the payload uploaded and downloaded.
+// An overhead is the ratio of wire to payload bytes and is left zero when no
+// payload moved in that direction.
+func (w *wireStats) report(uploaded, downloaded int64) *wireReport {
+	r := &wireReport{
+		SentBytes:       w.sent.Load(),
+		ReceivedBytes:   w.received.Load(),
+		UploadedBytes:   uploaded,
+		DownloadedBytes: downloaded,
+	}
+	if uploaded > 0 {
+		r.SentOverhead = float64(r.SentBytes) / float64(uploaded)
+	}
+	if downloaded > 0 {
+		r.ReceiveOverhead = float64(r.ReceivedBytes) / float64(downloaded)
+	}
+	return r
+}
+
+func (r *wireReport) String() string {
+	return fmt.Sprintf("sent %d for %d payload bytes (%.3fx), received %d for %d payload bytes (%.3fx)",
+		r.SentBytes, r.UploadedBytes, r.SentOverhead, r.ReceivedBytes, r.DownloadedBytes, r.ReceiveOverhead)
+}
+
+// countingDialer wraps dial, or a default dialer when nil, so the
+// connections it opens count their traffic into w.
+func countingDialer(w *wireStats, dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &countingConn{Conn: c, w: w}, nil
+	}
+}
+
+type countingConn struct {
+	net.Conn
+	w *wireStats
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.w.received.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.w.sent.Add(int64(n))
+	return n, err
+}
+
+// wireBytesHandler is a gRPC stats.Handler summing the wire length of every
+// message, header and trailer. gRPC doesn't report the wire length of
+// outgoing headers, so those are missing from sent.
+type wireBytesHandler struct {
+	w *wireStats
+}
+
+func (h *wireBytesHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *wireBytesHandler) HandleRPC(_ context.Context, s stats.RPCStats) {
+	switch s := s.(type) {
+	case *stats.OutPayload:
+		h.w.sent.Add(int64(s.WireLength))
+	case *stats.InPayload:
+		h.w.received.Add(int64(s.WireLength))
+	case *stats.InHeader:
+		h.w.received.Add(int64(s.WireLength))
+	case *stats.InTrailer:
+		h.w.received.Add(int64(s.WireLength))
+	}
+}
+
+func (h *wireBytesHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *wireBytesHandler) HandleConn(context.Context, stats.ConnStats) {}
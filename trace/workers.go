@@ -0,0 +1,36 @@
+package main
+
+import "sync"
+
+// forEach calls fn for every name using n concurrent workers and returns the
+// first error encountered. All names are processed regardless of errors.
+// fn is passed the index of the worker running it, from 0 to n-1.
+func forEach(names []string, n int, fn func(worker int, name string) error) error {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		first error
+		jobs  = make(chan string)
+	)
+	for w := range max(n, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				if err := fn(w, name); err != nil {
+					mu.Lock()
+					if first == nil {
+						first = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, name := range names {
+		jobs <- name
+	}
+	close(jobs)
+	wg.Wait()
+	return first
+}